@@ -1,25 +1,108 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"example/go-gin-library-api/internal/bootstrap"
+	"example/go-gin-library-api/internal/config"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	config, err := bootstrap.LoadAuthConfigFromEnv()
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
-	authHandler, bookHandler, err := bootstrap.BuildDeps(config)
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err.Error())
+		}
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("invalid configuration:\n%s", err.Error())
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%s", err.Error())
+	}
+
+	deps, err := bootstrap.BuildDeps(cfg)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
-	r, err := newRouter(authHandler, bookHandler)
+	r, err := newRouter(deps)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
-	r.Run(config.Addr)
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      r,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+		TLSConfig:    deps.TLSConfig,
+	}
+
+	// ctx is cancelled on the first SIGINT/SIGTERM; a second signal kills the process right away
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Printf("Listening on %s (TLS)", cfg.Server.Addr)
+			serveErr <- srv.ListenAndServeTLS("", "") // the certificate comes from TLSConfig.GetCertificate
+			return
+		}
+
+		log.Printf("Listening on %s", cfg.Server.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// failed makes the process exit non-zero once the hooks ran, so the
+	// orchestrator doesn't take a server that never listened for a clean exit
+	failed := false
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("ListenAndServe: %s", err.Error())
+			failed = true
+		}
+	case <-ctx.Done():
+		log.Print("Shutdown signal received, draining requests")
+	}
+	stop()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Printf("srv.Shutdown: %s", err.Error())
+	}
+	cancel()
+
+	// the hooks get a budget of their own, a slow drain mustn't leave them an expired context
+	hooksCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	if err := deps.Lifecycle.Shutdown(hooksCtx); err != nil {
+		log.Printf("Lifecycle.Shutdown: %s", err.Error())
+	}
+	cancel()
+
+	log.Print("Server stopped")
+
+	if failed {
+		os.Exit(1)
+	}
 }
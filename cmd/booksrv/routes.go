@@ -2,23 +2,55 @@ package main
 
 import (
 	"example/go-gin-library-api/internal/auth"
-	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/bootstrap"
+	"example/go-gin-library-api/internal/tracing"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func newRouter(authHandler *auth.Handler, bookHandler *book.Handler) (*gin.Engine, error) {
+func newRouter(deps *bootstrap.Deps) (*gin.Engine, error) {
 	router := gin.Default()
+	if err := router.SetTrustedProxies(deps.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("router.SetTrustedProxies: %w", err)
+	}
+	router.ContextWithFallback = true // lets handlers pass *gin.Context down while keeping the request span
+	router.Use(otelgin.Middleware(tracing.ServiceName), deps.Metrics.HTTP())
+
+	router.GET("/metrics", gin.WrapH(deps.Metrics.Handler()))
+	router.GET("/healthz", deps.HealthHandler.Liveness)
+	router.GET("/readyz", deps.HealthHandler.Readiness)
+	router.POST("/auth/token", deps.RateLimits.Token, deps.Metrics.Tokens(), auth.AuditTokens(deps.AuditLog), deps.AuthHandler.RequestAuth)
+	router.GET("/auth/authorize", deps.RateLimits.Authorize, deps.AuthHandler.Authorize)
+	router.POST("/auth/authorize", deps.RateLimits.Authorize, deps.AuthHandler.Approve)
+	router.POST("/patrons", deps.RateLimits.Authorize, deps.PatronHandler.Register)
+	router.POST("/auth/revoke", deps.RateLimits.Token, deps.AuthHandler.Revoke)
+	router.POST("/auth/introspect", deps.RateLimits.Token, deps.AuthHandler.Introspect)
+	router.GET("/.well-known/jwks.json", deps.AuthHandler.JWKS)
+	router.GET("/.well-known/oauth-authorization-server", deps.AuthHandler.Metadata)
 
-	router.POST("/auth/token", authHandler.RequestAuth)
+	api := router.Group("/api", deps.AuthHandler.RequireAuth(), deps.RateLimits.API)
+	{
+		api.GET("/books", auth.RequireScope(auth.ScopeBooksRead), deps.BookHandler.FindAll)
+		api.GET("/books/:id", auth.RequireScope(auth.ScopeBooksRead), deps.BookHandler.GetById)
+		api.POST("/books", auth.RequireScope(auth.ScopeBooksWrite), deps.BookHandler.Create)
+		api.PATCH("/checkout", auth.RequireScope(auth.ScopeCirculation), deps.BookHandler.Checkout)
+		api.PATCH("/return", auth.RequireScope(auth.ScopeCirculation), deps.BookHandler.Return)
+		api.GET("/patrons/me", auth.RequireScope(auth.ScopeProfile), deps.PatronHandler.Me)
+	}
 
-	api := router.Group("/api", authHandler.RequireAuth())
+	admin := api.Group("/admin", auth.RequireScope(auth.ScopeAdmin))
 	{
-		api.GET("/books", bookHandler.FindAll)
-		api.GET("/books/:id", bookHandler.GetById)
-		api.POST("/books", bookHandler.Create)
-		api.PATCH("/checkout", bookHandler.Checkout)
-		api.PATCH("/return", bookHandler.Return)
+		admin.GET("/clients", deps.AdminHandler.List)
+		admin.POST("/clients", deps.AdminHandler.Create)
+		admin.PATCH("/clients/:id/disable", deps.AdminHandler.Disable)
+		admin.PATCH("/clients/:id/enable", deps.AdminHandler.Enable)
+		admin.PUT("/clients/:id/scopes", deps.AdminHandler.SetScopes)
+		admin.POST("/clients/:id/rotate", deps.AdminHandler.RotateSecret)
+		admin.DELETE("/clients/:id", deps.AdminHandler.Delete)
+		admin.GET("/audit", deps.AuditHandler.List)
+		admin.GET("/audit/verify", deps.AuditHandler.Verify)
 	}
 
 	return router, nil
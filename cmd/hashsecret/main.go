@@ -0,0 +1,28 @@
+// Command hashsecret prints the argon2id hash of a client secret, ready to be
+// used as CLIENT_SECRET_HASH or stored in the clients file/table.
+//
+//	go run ./cmd/hashsecret < secret.txt
+package main
+
+import (
+	"bufio"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal("reading secret from stdin: ", err.Error())
+	}
+
+	hash, err := secret.Hash(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fmt.Println(hash)
+}
@@ -0,0 +1,122 @@
+// Command migrate applies or rolls back the schema migrations of the SQL book
+// store, configured the same way as booksrv (.env, environment, --config, flags).
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate up
+//	go run ./cmd/migrate down [steps]
+package main
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/migrate"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlStore is a store with schema migrations.
+type sqlStore interface {
+	io.Closer
+	Migrator() (*migrate.Migrator, error)
+}
+
+func main() {
+	cfg, opts, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if len(opts.Args) == 0 {
+		log.Fatal("usage: migrate [flags] status | up | down [steps]")
+	}
+
+	store, err := open(cfg.Store)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer store.Close()
+
+	m, err := store.Migrator()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if err := run(context.Background(), m, opts.Args); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func open(cfg config.Store) (sqlStore, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "mysql":
+		return stores.NewMySQL(cfg.MySQLDSN)
+	case "postgres":
+		return stores.NewPostgres(cfg.PostgresDSN)
+	case "sqlite":
+		return stores.NewSQLite(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("store %q has no schema migrations (BOOK_STORE must be mysql, postgres or sqlite)", cfg.Driver)
+	}
+}
+
+func run(ctx context.Context, m *migrate.Migrator, args []string) error {
+	switch args[0] {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+			steps = n
+		}
+
+		rolledBack, err := m.Down(ctx, steps)
+		for _, mig := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(rolledBack) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+		return err
+
+	default:
+		return fmt.Errorf("unknown command %q, want status, up or down", args[0])
+	}
+}
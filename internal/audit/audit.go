@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Actions recorded outside of the client administration (see the auth package for those).
+const (
+	ActionTokenIssued    = "token.issued"
+	ActionTokenDenied    = "token.denied"
+	ActionBookCreated    = "book.created"
+	ActionBookCheckedOut = "book.checked_out"
+	ActionBookReturned   = "book.returned"
+)
+
+var (
+	// ErrConflict is returned by a sink when an event with the same sequence
+	// number was appended concurrently (by another instance sharing it).
+	ErrConflict = fmt.Errorf("audit event sequence already taken")
+	// ErrTampered is returned by Verify when the chain is broken.
+	ErrTampered = fmt.Errorf("audit log was tampered with")
+)
+
+// Event is one entry of the audit trail. Every event carries the hash of the
+// previous one, so removing or editing an entry breaks the chain after it.
+type Event struct {
+	Seq     int64             `json:"seq"`
+	At      time.Time         `json:"at"`
+	Actor   string            `json:"actor"`            // id of the client that acted, or tried to
+	Action  string            `json:"action"`           // e.g. token.issued, book.checked_out
+	Target  string            `json:"target,omitempty"` // id of what was acted on
+	Reason  string            `json:"reason,omitempty"` // why it was denied
+	IP      string            `json:"ip,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+	Prev    string            `json:"prev"` // hash of the previous event, empty for the first one
+	Hash    string            `json:"hash"`
+}
+
+// ComputeHash is the SHA-256 of the event without its own hash.
+func (e Event) ComputeHash() string {
+	e.Hash = ""
+
+	// a struct with a string map always marshals, with sorted map keys
+	bytes, _ := json.Marshal(e)
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// Query filters the events returned by Log.Query. Zero fields don't filter.
+type Query struct {
+	Since, Until time.Time
+	Actor        string
+	Action       string
+	Before       int64 // only events with a lower Seq, to page back
+	Limit        int
+}
+
+// Matches reports whether e passes the filters of q, Limit aside.
+func (q Query) Matches(e Event) bool {
+	switch {
+	case !q.Since.IsZero() && e.At.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && e.At.After(q.Until):
+		return false
+	case q.Actor != "" && e.Actor != q.Actor:
+		return false
+	case q.Action != "" && e.Action != q.Action:
+		return false
+	case q.Before > 0 && e.Seq >= q.Before:
+		return false
+	}
+
+	return true
+}
+
+// Sink stores the events. Events are only ever appended, never changed.
+type Sink interface {
+	// Append stores e, returning ErrConflict if its Seq is already taken.
+	Append(ctx context.Context, e Event) error
+	// Last returns the newest event, the zero Event when there is none.
+	Last(ctx context.Context) (Event, error)
+	// Query returns the matching events, newest first, at most q.Limit of them.
+	Query(ctx context.Context, q Query) ([]Event, error)
+	// Scan calls fn with every event, oldest first.
+	Scan(ctx context.Context, fn func(Event) error) error
+	io.Closer
+}
+
+// Log chains the events and appends them to a sink.
+type Log struct {
+	mu   sync.Mutex
+	sink Sink
+	last Event
+}
+
+// New resumes the chain at the newest event of sink.
+func New(ctx context.Context, sink Sink) (*Log, error) {
+	last, err := sink.Last(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("sink.Last: %w", err)
+	}
+
+	return &Log{sink: sink, last: last}, nil
+}
+
+// Record chains e to the previous event and appends it. Seq, Prev, Hash and,
+// when zero, At are filled in.
+func (l *Log) Record(ctx context.Context, e Event) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	// what every sink can store back exactly, so the hash still matches once read
+	e.At = e.At.UTC().Truncate(time.Microsecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		e.Seq = l.last.Seq + 1
+		e.Prev = l.last.Hash
+		e.Hash = e.ComputeHash()
+
+		err := l.sink.Append(ctx, e)
+		if err == nil {
+			l.last = e
+			return nil
+		}
+
+		if !errors.Is(err, ErrConflict) || attempt == 2 {
+			return fmt.Errorf("sink.Append: %w", err)
+		}
+
+		// another instance appended first, continue the chain after its event
+		if l.last, err = l.sink.Last(ctx); err != nil {
+			return fmt.Errorf("sink.Last: %w", err)
+		}
+	}
+}
+
+// Query returns the events matching q, newest first.
+func (l *Log) Query(ctx context.Context, q Query) ([]Event, error) {
+	out, err := l.sink.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("sink.Query: %w", err)
+	}
+
+	return out, nil
+}
+
+// Verify walks the whole chain and returns how many events it checked. A
+// broken chain is reported with ErrTampered and the first bad sequence number.
+func (l *Log) Verify(ctx context.Context) (int64, error) {
+	var prev Event
+	err := l.sink.Scan(ctx, func(e Event) error {
+		switch {
+		case e.Seq != prev.Seq+1:
+			return fmt.Errorf("%w: event %d follows event %d", ErrTampered, e.Seq, prev.Seq)
+		case e.Prev != prev.Hash:
+			return fmt.Errorf("%w: event %d doesn't chain to the previous one", ErrTampered, e.Seq)
+		case e.Hash != e.ComputeHash():
+			return fmt.Errorf("%w: event %d was modified", ErrTampered, e.Seq)
+		}
+
+		prev = e
+		return nil
+	})
+	if err != nil {
+		return prev.Seq, err
+	}
+
+	// the chain can't tell its newest events were cut off, but this instance remembers them
+	l.mu.Lock()
+	last := l.last.Seq
+	l.mu.Unlock()
+	if prev.Seq < last {
+		return prev.Seq, fmt.Errorf("%w: events after %d are missing", ErrTampered, prev.Seq)
+	}
+
+	return prev.Seq, nil
+}
+
+// Close closes the sink.
+func (l *Log) Close() error {
+	return l.sink.Close()
+}
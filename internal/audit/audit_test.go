@@ -0,0 +1,160 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/audit"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// sliceSink keeps the events in a slice the tests can tamper with.
+type sliceSink struct {
+	events []audit.Event
+}
+
+func (s *sliceSink) Append(ctx context.Context, e audit.Event) error {
+	if len(s.events) > 0 && e.Seq <= s.events[len(s.events)-1].Seq {
+		return audit.ErrConflict
+	}
+
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *sliceSink) Last(ctx context.Context) (audit.Event, error) {
+	if len(s.events) == 0 {
+		return audit.Event{}, nil
+	}
+
+	return s.events[len(s.events)-1], nil
+}
+
+func (s *sliceSink) Query(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+	return nil, nil
+}
+
+func (s *sliceSink) Scan(ctx context.Context, fn func(audit.Event) error) error {
+	for _, e := range s.events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sliceSink) Close() error { return nil }
+
+// newLog records n events to a fresh sliceSink.
+func newLog(t *testing.T, n int) (*audit.Log, *sliceSink) {
+	t.Helper()
+
+	sink := &sliceSink{}
+	l, err := audit.New(context.Background(), sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range n {
+		e := audit.Event{Actor: "c1", Action: audit.ActionBookCheckedOut, Target: fmt.Sprintf("b%d", i), Details: map[string]string{"quantity": "1"}}
+		if err := l.Record(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return l, sink
+}
+
+func TestVerifyIntactChain(t *testing.T) {
+	l, sink := newLog(t, 5)
+
+	n, err := l.Verify(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("Verify = %d, %v, want 5 events", n, err)
+	}
+
+	for i, e := range sink.events {
+		if e.Seq != int64(i+1) || e.Hash != e.ComputeHash() {
+			t.Errorf("event %d: seq %d, hash %s", i, e.Seq, e.Hash)
+		}
+		if i > 0 && e.Prev != sink.events[i-1].Hash {
+			t.Errorf("event %d doesn't chain to the previous one", e.Seq)
+		}
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := map[string]struct {
+		tamper func(events []audit.Event) []audit.Event
+		seq    int64 // last good event
+	}{
+		"edited event": {
+			tamper: func(events []audit.Event) []audit.Event {
+				events[2].Actor = "someone else"
+				return events
+			},
+			seq: 2,
+		},
+		"edited event with its hash recomputed": {
+			tamper: func(events []audit.Event) []audit.Event {
+				events[2].Target = "b42"
+				events[2].Hash = events[2].ComputeHash()
+				return events
+			},
+			seq: 3,
+		},
+		"edited details": {
+			tamper: func(events []audit.Event) []audit.Event {
+				events[1].Details = map[string]string{"quantity": "100"}
+				return events
+			},
+			seq: 1,
+		},
+		"removed middle event": {
+			tamper: func(events []audit.Event) []audit.Event {
+				return slices.Delete(events, 2, 3)
+			},
+			seq: 2,
+		},
+		"truncated tail": {
+			tamper: func(events []audit.Event) []audit.Event {
+				return events[:3]
+			},
+			seq: 3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, sink := newLog(t, 5)
+			sink.events = tt.tamper(sink.events)
+
+			n, err := l.Verify(context.Background())
+			if !errors.Is(err, audit.ErrTampered) {
+				t.Fatalf("Verify = %v, want %v", err, audit.ErrTampered)
+			}
+			if n != tt.seq {
+				t.Errorf("Verify stopped after event %d, want %d", n, tt.seq)
+			}
+		})
+	}
+}
+
+func TestRecordResumesChain(t *testing.T) {
+	_, sink := newLog(t, 3)
+
+	// another instance, or a restart, continues after the newest event
+	l, err := audit.New(context.Background(), sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(context.Background(), audit.Event{Actor: "c2", Action: audit.ActionTokenIssued}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := l.Verify(context.Background()); err != nil || n != 4 {
+		t.Errorf("Verify = %d, %v, want 4 events", n, err)
+	}
+}
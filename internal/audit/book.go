@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+	"log"
+)
+
+// BookService decorates a book.Service, recording every change to the books
+// with the client that made it. Reads are promoted from the embedded service.
+type BookService struct {
+	book.Service
+	log *Log
+}
+
+func NewBookService(next book.Service, log *Log) *BookService {
+	return &BookService{Service: next, log: log}
+}
+
+func (s *BookService) Create(ctx context.Context, request book.BookRequest) (string, error) {
+	id, err := s.Service.Create(ctx, request)
+	if err == nil {
+		s.record(ctx, ActionBookCreated, id, map[string]string{"title": request.Title, "author": request.Author})
+	}
+
+	return id, err
+}
+
+func (s *BookService) Checkout(ctx context.Context, id string) (book.Book, error) {
+	out, err := s.Service.Checkout(ctx, id)
+	if err == nil {
+		s.record(ctx, ActionBookCheckedOut, id, nil)
+	}
+
+	return out, err
+}
+
+func (s *BookService) Return(ctx context.Context, id string) (book.Book, error) {
+	out, err := s.Service.Return(ctx, id)
+	if err == nil {
+		s.record(ctx, ActionBookReturned, id, nil)
+	}
+
+	return out, err
+}
+
+// record logs failures instead of returning them: the change is already made,
+// failing the request now would only tell the client something untrue.
+func (s *BookService) record(ctx context.Context, action, id string, details map[string]string) {
+	// set on the gin context by auth.RequireAuth
+	actor, _ := ctx.Value("client_id").(string)
+	if sub, _ := ctx.Value("sub").(string); sub != "" {
+		if details == nil {
+			details = map[string]string{}
+		}
+		details["patron"] = sub
+	}
+
+	e := Event{Actor: actor, Action: action, Target: id, Details: details}
+	if err := s.log.Record(ctx, e); err != nil {
+		log.Printf("audit.Record %s %s: %s", action, id, err.Error())
+	}
+}
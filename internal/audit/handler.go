@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Handler exposes the audit trail to admins.
+type Handler struct {
+	log *Log
+}
+
+func NewHandler(log *Log) *Handler {
+	h := Handler{
+		log: log,
+	}
+
+	return &h
+}
+
+// List returns the events, newest first. Filters: since and until (RFC 3339),
+// actor, action, limit (default 100) and before, a seq to page back from.
+func (h *Handler) List(ctx *gin.Context) {
+	q, err := parseQuery(ctx)
+	if err != nil {
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.log.Query(ctx, q)
+	if err != nil {
+		log.Printf("audit.Query: %s", err.Error())
+		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "could not read the audit log"})
+		return
+	}
+
+	if events == nil {
+		events = []Event{}
+	}
+
+	ctx.IndentedJSON(http.StatusOK, events)
+}
+
+// Verify checks the hash chain of the whole trail.
+func (h *Handler) Verify(ctx *gin.Context) {
+	checked, err := h.log.Verify(ctx)
+	if errors.Is(err, ErrTampered) {
+		ctx.IndentedJSON(http.StatusOK, gin.H{"valid": false, "checked": checked, "error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		log.Printf("audit.Verify: %s", err.Error())
+		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "could not read the audit log"})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, gin.H{"valid": true, "checked": checked})
+}
+
+func parseQuery(ctx *gin.Context) (Query, error) {
+	q := Query{
+		Actor:  ctx.Query("actor"),
+		Action: ctx.Query("action"),
+		Limit:  defaultLimit,
+	}
+
+	var err error
+	if raw := ctx.Query("since"); raw != "" {
+		if q.Since, err = time.Parse(time.RFC3339, raw); err != nil {
+			return Query{}, fmt.Errorf("since must be an RFC 3339 time")
+		}
+	}
+	if raw := ctx.Query("until"); raw != "" {
+		if q.Until, err = time.Parse(time.RFC3339, raw); err != nil {
+			return Query{}, fmt.Errorf("until must be an RFC 3339 time")
+		}
+	}
+	if raw := ctx.Query("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit <= 0 || q.Limit > maxLimit {
+			return Query{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if raw := ctx.Query("before"); raw != "" {
+		if q.Before, err = strconv.ParseInt(raw, 10, 64); err != nil || q.Before <= 0 {
+			return Query{}, fmt.Errorf("before must be a positive sequence number")
+		}
+	}
+
+	return q, nil
+}
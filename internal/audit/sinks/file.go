@@ -0,0 +1,138 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"example/go-gin-library-api/internal/audit"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// File appends one JSON event per line to a file opened in append-only mode;
+// existing lines are never rewritten. Only one process may write to it.
+type File struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	last audit.Event
+}
+
+// NewFile opens (or creates) the audit file at path.
+func NewFile(path string) (*File, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	s := &File{path: path, file: f}
+	err = s.Scan(context.Background(), func(e audit.Event) error {
+		s.last = e
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Append writes e as a single line and syncs it to disk before returning.
+func (s *File) Append(ctx context.Context, e audit.Event) error {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Seq <= s.last.Seq {
+		return audit.ErrConflict
+	}
+
+	if _, err := s.file.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.last = e
+	return nil
+}
+
+func (s *File) Last(ctx context.Context) (audit.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last, nil
+}
+
+// Query reads the whole file; audit queries are rare enough for that.
+func (s *File) Query(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+	var out []audit.Event
+	err := s.Scan(ctx, func(e audit.Event) error {
+		if q.Matches(e) {
+			out = append(out, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	slices.Reverse(out)
+
+	return out, nil
+}
+
+// Scan reads the events from a separate handle, so appends aren't blocked.
+func (s *File) Scan(ctx context.Context, fn func(audit.Event) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e audit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s line %d: %w", s.path, line, err)
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *File) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,124 @@
+package sinks_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/audit"
+	"example/go-gin-library-api/internal/audit/sinks"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := context.Background()
+
+	sink, err := sinks.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := audit.New(ctx, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a local time with nanoseconds, which the log must store in a form that reads back exactly
+	at := time.Date(2026, 3, 1, 9, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	events := []audit.Event{
+		{At: at, Actor: "c1", Action: audit.ActionTokenIssued, IP: "10.0.0.1", Details: map[string]string{"scope": "books:read", "grant": "client_credentials"}},
+		{Actor: "c1", Action: audit.ActionBookCheckedOut, Target: "b1"},
+		{Actor: "c2", Action: audit.ActionTokenDenied, Reason: "invalid_client", IP: "10.0.0.2"},
+	}
+	for _, e := range events {
+		if err := l.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read back by a new process
+	sink, err = sinks.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = audit.New(ctx, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var read []audit.Event
+	err = sink.Scan(ctx, func(e audit.Event) error {
+		read = append(read, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != len(events) {
+		t.Fatalf("read %d events, want %d", len(read), len(events))
+	}
+	for _, e := range read {
+		if e.Hash != e.ComputeHash() {
+			t.Errorf("event %d read back hashes to %s, stored %s", e.Seq, e.ComputeHash(), e.Hash)
+		}
+	}
+	if !read[0].At.Equal(at.Truncate(time.Microsecond)) {
+		t.Errorf("At read back as %s", read[0].At)
+	}
+
+	if n, err := l.Verify(ctx); err != nil || n != 3 {
+		t.Fatalf("Verify after reopening = %d, %v, want 3 events", n, err)
+	}
+
+	// the chain goes on after the events read back
+	if err := l.Record(ctx, audit.Event{Actor: "c1", Action: audit.ActionBookReturned, Target: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := l.Verify(ctx); err != nil || n != 4 {
+		t.Errorf("Verify after appending = %d, %v, want 4 events", n, err)
+	}
+}
+
+func TestFileEditedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := context.Background()
+
+	sink, err := sinks.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := audit.New(ctx, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, target := range []string{"b1", "b2", "b3"} {
+		if err := l.Record(ctx, audit.Event{Actor: "c1", Action: audit.ActionBookCheckedOut, Target: target}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := bytes.Replace(content, []byte(`"target":"b2"`), []byte(`"target":"b9"`), 1)
+	if bytes.Equal(edited, content) {
+		t.Fatal("nothing to edit")
+	}
+	if err := os.WriteFile(path, edited, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := l.Verify(ctx); !errors.Is(err, audit.ErrTampered) || n != 1 {
+		t.Errorf("Verify of an edited file = %d, %v, want %v after event 1", n, err, audit.ErrTampered)
+	}
+}
@@ -0,0 +1,16 @@
+-- baseline, same as the table go-gin-library-infra/db/init.sql used to create: a no-op on those databases
+CREATE TABLE IF NOT EXISTS AuditEvents (
+    seq bigint NOT NULL,
+    at datetime(6) NOT NULL,
+    actor varchar(255) NOT NULL DEFAULT '',
+    action varchar(64) NOT NULL,
+    target varchar(255) NOT NULL DEFAULT '',
+    reason varchar(512) NOT NULL DEFAULT '',
+    ip varchar(64) NOT NULL DEFAULT '',
+    details json NULL,
+    prev_hash char(64) NOT NULL DEFAULT '',
+    hash char(64) NOT NULL,
+    PRIMARY KEY (seq),
+    KEY ix_audit_at (at),
+    KEY ix_audit_actor (actor, at)
+);
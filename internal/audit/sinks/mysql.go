@@ -0,0 +1,205 @@
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"example/go-gin-library-api/internal/audit"
+	"example/go-gin-library-api/internal/migrate"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDupEntry is the MySQL error number for a duplicate key.
+const errDupEntry = 1062
+
+// MySQL keeps the events in the AuditEvents table, created by Migrator.
+// seq is the primary key, so instances sharing the table can't fork the chain.
+type MySQL struct {
+	DB *sql.DB
+}
+
+func NewMySQL(dsn string) (*MySQL, error) {
+	dsnCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql.ParseDSN: %w", err)
+	}
+	dsnCfg.ParseTime = true
+	dsnCfg.Loc = time.UTC // events are hashed with UTC times
+
+	db, err := sql.Open("mysql", dsnCfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.Ping: %w", err)
+	}
+
+	return &MySQL{DB: db}, nil
+}
+
+// migrations holds the schema of the AuditEvents table. Add a new numbered file to
+// change it; never edit an applied one.
+//
+//go:embed migrations/mysql
+var migrations embed.FS
+
+// Migrator applies the schema migrations of migrations/mysql, recorded in schema_migrations_audit
+// so they don't clash with those of the other tables.
+func (s *MySQL) Migrator() (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations/mysql")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithHistory(s.DB, migrate.MySQL, sub, "schema_migrations_audit")
+}
+
+const eventColumns = `seq, at, actor, action, target, reason, ip, details, prev_hash, hash`
+
+func (s *MySQL) Append(ctx context.Context, e audit.Event) error {
+	const q = `INSERT INTO AuditEvents (` + eventColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	var details any // NULL without details
+	if len(e.Details) > 0 {
+		bytes, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		details = string(bytes)
+	}
+
+	_, err := s.DB.ExecContext(ctx, q, e.Seq, e.At, e.Actor, e.Action, e.Target, e.Reason, e.IP, details, e.Prev, e.Hash)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDupEntry {
+		return audit.ErrConflict
+	}
+
+	return err
+}
+
+func (s *MySQL) Last(ctx context.Context) (audit.Event, error) {
+	const q = `SELECT ` + eventColumns + ` FROM AuditEvents ORDER BY seq DESC LIMIT 1;`
+
+	rows, err := s.DB.QueryContext(ctx, q)
+	if err != nil {
+		return audit.Event{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return audit.Event{}, rows.Err()
+	}
+
+	return scanEvent(rows)
+}
+
+func (s *MySQL) Query(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if !q.Since.IsZero() {
+		where, args = append(where, "at >= ?"), append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		where, args = append(where, "at <= ?"), append(args, q.Until.UTC())
+	}
+	if q.Actor != "" {
+		where, args = append(where, "actor = ?"), append(args, q.Actor)
+	}
+	if q.Action != "" {
+		where, args = append(where, "action = ?"), append(args, q.Action)
+	}
+	if q.Before > 0 {
+		where, args = append(where, "seq < ?"), append(args, q.Before)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM AuditEvents`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY seq DESC`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []audit.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+
+	return out, rows.Err()
+}
+
+func (s *MySQL) Scan(ctx context.Context, fn func(audit.Event) error) error {
+	const q = `SELECT ` + eventColumns + ` FROM AuditEvents ORDER BY seq;`
+
+	rows, err := s.DB.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanEvent(rows *sql.Rows) (audit.Event, error) {
+	var (
+		e       audit.Event
+		details sql.NullString
+	)
+	err := rows.Scan(&e.Seq, &e.At, &e.Actor, &e.Action, &e.Target, &e.Reason, &e.IP, &details, &e.Prev, &e.Hash)
+	if err != nil {
+		return audit.Event{}, err
+	}
+
+	if details.Valid {
+		if err := json.Unmarshal([]byte(details.String), &e.Details); err != nil {
+			return audit.Event{}, fmt.Errorf("event %d details: %w", e.Seq, err)
+		}
+	}
+
+	return e, nil
+}
+
+// Close closes the underlying connection pool.
+func (s *MySQL) Close() error {
+	return s.DB.Close()
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *MySQL) Check(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
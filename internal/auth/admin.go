@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes the client management endpoints.
+type AdminHandler struct {
+	service *ClientService
+}
+
+func NewAdminHandler(service *ClientService) *AdminHandler {
+	a := AdminHandler{
+		service: service,
+	}
+
+	return &a
+}
+
+// List returns every registered client.
+func (h *AdminHandler) List(ctx *gin.Context) {
+	clients, err := h.service.List(ctx)
+	if err != nil {
+		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	out := make([]ClientResponse, 0, len(clients))
+	for _, c := range clients {
+		out = append(out, newClientResponse(c))
+	}
+
+	ctx.IndentedJSON(http.StatusOK, out)
+}
+
+// Create registers a client and returns its secret, which is never shown again.
+func (h *AdminHandler) Create(ctx *gin.Context) {
+	var request ClientRequest
+
+	if err := ctx.BindJSON(&request); err != nil {
+		text := fmt.Sprintf("BindJSON: %s", err.Error())
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": text})
+		return
+	}
+
+	c, plain, err := h.service.Create(ctx, ctx.GetString("client_id"), request)
+	if err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusCreated, ClientSecretResponse{ClientResponse: newClientResponse(c), ClientSecret: plain})
+}
+
+// Disable stops a client from getting new tokens.
+func (h *AdminHandler) Disable(ctx *gin.Context) {
+	c, err := h.service.SetDisabled(ctx, ctx.GetString("client_id"), ctx.Param("id"), true)
+	if err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, newClientResponse(c))
+}
+
+// Enable lets a disabled client get tokens again.
+func (h *AdminHandler) Enable(ctx *gin.Context) {
+	c, err := h.service.SetDisabled(ctx, ctx.GetString("client_id"), ctx.Param("id"), false)
+	if err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, newClientResponse(c))
+}
+
+// Delete removes a client.
+func (h *AdminHandler) Delete(ctx *gin.Context) {
+	if err := h.service.Delete(ctx, ctx.GetString("client_id"), ctx.Param("id")); err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// SetScopes replaces the scopes a client can be granted. Access tokens already
+// issued keep their scopes until they expire; refreshing them drops the removed ones.
+func (h *AdminHandler) SetScopes(ctx *gin.Context) {
+	var request ScopesRequest
+
+	if err := ctx.BindJSON(&request); err != nil {
+		text := fmt.Sprintf("BindJSON: %s", err.Error())
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": text})
+		return
+	}
+
+	c, err := h.service.SetScopes(ctx, ctx.GetString("client_id"), ctx.Param("id"), request.Scopes)
+	if err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, newClientResponse(c))
+}
+
+// RotateSecret generates a new secret for a client and returns it once.
+func (h *AdminHandler) RotateSecret(ctx *gin.Context) {
+	c, plain, err := h.service.RotateSecret(ctx, ctx.GetString("client_id"), ctx.Param("id"))
+	if err != nil {
+		ctx.IndentedJSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, ClientSecretResponse{ClientResponse: newClientResponse(c), ClientSecret: plain})
+}
+
+func newClientResponse(c Client) ClientResponse {
+	return ClientResponse{ID: c.ID, Name: c.Name, CreatedAt: c.CreatedAt, Disabled: c.Disabled, Scopes: c.Scopes, RedirectURIs: c.RedirectURIs, TLSSubject: c.TLSSubject, TLSSANs: c.TLSSANs}
+}
+
+// statusFor maps the client management errors to HTTP status codes.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrClientNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrClientDuplicate):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidScope), errors.Is(err, ErrInvalidRedirectURI):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
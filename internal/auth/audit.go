@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/audit"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLog records security events, implemented by audit.Log.
+type AuditLog interface {
+	Record(ctx context.Context, e audit.Event) error
+}
+
+// AuditTokens records the outcome of the token endpoint: who got a token, or
+// who was denied one and why. Denied requests name the client they claimed to be.
+func AuditTokens(auditLog AuditLog) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		e := audit.Event{
+			Actor:   ctx.GetString("client_id"),
+			Action:  audit.ActionTokenIssued,
+			IP:      ctx.ClientIP(),
+			Details: map[string]string{"grant_type": ctx.PostForm("grant_type")},
+		}
+
+		if ctx.Writer.Status() == http.StatusOK {
+			e.Details["scope"] = ctx.GetString("token_scope")
+		} else {
+			e.Action = audit.ActionTokenDenied
+			if e.Actor == "" {
+				e.Actor, _, _ = clientCredentials(ctx)
+			}
+
+			e.Reason = http.StatusText(ctx.Writer.Status())
+			if last := ctx.Errors.Last(); last != nil {
+				e.Reason = last.Error()
+			}
+		}
+
+		if err := auditLog.Record(ctx, e); err != nil {
+			log.Printf("audit.Record %s: %s", e.Action, err.Error())
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"errors"
+	"example/go-gin-library-api/internal/secret"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identity is the person a patron login resolves to.
+type Identity struct {
+	Subject string
+	Name    string
+	Email   string
+}
+
+// Authenticator checks the credentials typed on the login page. It reports
+// ErrInvalidCredentials for a wrong username or password.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (Identity, error)
+}
+
+// GrantAuthorizationCode is the grant type exchanging a code from /auth/authorize.
+const GrantAuthorizationCode = "authorization_code"
+
+// csrfCookie carries the double-submit token of the login form.
+const csrfCookie = "authorize_csrf"
+
+var scopeDescriptions = map[string]string{
+	ScopeBooksRead:   "browse the catalogue",
+	ScopeCirculation: "check out and return books for you",
+	ScopeOpenID:      "know who you are",
+	ScopeProfile:     "see your name and email",
+}
+
+//go:embed templates/authorize.html
+var authorizePage string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizePage))
+
+// authorizeRequest holds the parameters of an authorization request (RFC 6749
+// section 4.1.1 and RFC 7636), carried through the login form as hidden fields.
+type authorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+func readAuthorizeRequest(get func(string) string) authorizeRequest {
+	return authorizeRequest{
+		ResponseType:        get("response_type"),
+		ClientID:            get("client_id"),
+		RedirectURI:         get("redirect_uri"),
+		Scope:               get("scope"),
+		State:               get("state"),
+		Nonce:               get("nonce"),
+		CodeChallenge:       get("code_challenge"),
+		CodeChallengeMethod: get("code_challenge_method"),
+	}
+}
+
+type authorizePageData struct {
+	Fatal      string
+	Error      string
+	ClientName string
+	Scopes     []string
+	CSRF       string
+	Email      string
+	Request    authorizeRequest
+}
+
+// Authorize shows the login and consent page of the authorization code flow.
+func (h *Handler) Authorize(ctx *gin.Context) {
+	req := readAuthorizeRequest(ctx.Query)
+
+	client, scopes, ok := h.checkAuthorizeRequest(ctx, req)
+	if !ok {
+		return
+	}
+
+	csrf, err := secret.Generate(16)
+	if err != nil {
+		log.Printf("Authorize: %s", err.Error())
+		renderAuthorize(ctx, http.StatusInternalServerError, authorizePageData{Fatal: "Something went wrong, please try again."})
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteStrictMode)
+	ctx.SetCookie(csrfCookie, csrf, 600, "/auth/authorize", "", ctx.Request.TLS != nil, true)
+
+	renderAuthorize(ctx, http.StatusOK, authorizePageData{
+		ClientName: displayName(client),
+		Scopes:     describeScopes(scopes),
+		CSRF:       csrf,
+		Request:    req,
+	})
+}
+
+// Approve handles the login form: it authenticates the patron and redirects back
+// to the client with a code, or with access_denied if the patron declined.
+func (h *Handler) Approve(ctx *gin.Context) {
+	req := readAuthorizeRequest(ctx.PostForm)
+
+	client, scopes, ok := h.checkAuthorizeRequest(ctx, req)
+	if !ok {
+		return
+	}
+
+	cookie, err := ctx.Cookie(csrfCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(ctx.PostForm("csrf"))) != 1 {
+		renderAuthorize(ctx, http.StatusForbidden, authorizePageData{Fatal: "The form expired, go back to the application and sign in again."})
+		return
+	}
+
+	if ctx.PostForm("action") != "approve" {
+		redirectError(ctx, req, "access_denied")
+		return
+	}
+
+	identity, err := h.opts.Patrons.Authenticate(ctx, ctx.PostForm("email"), ctx.PostForm("password"))
+	if err != nil {
+		status, message := http.StatusUnauthorized, "Wrong email or password."
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("Authenticate: %s", err.Error())
+			status, message = http.StatusInternalServerError, "Something went wrong, please try again."
+		}
+
+		renderAuthorize(ctx, status, authorizePageData{
+			Error:      message,
+			ClientName: displayName(client),
+			Scopes:     describeScopes(scopes),
+			CSRF:       cookie,
+			Email:      ctx.PostForm("email"),
+			Request:    req,
+		})
+		return
+	}
+
+	code, err := h.service.CreateCode(ctx, AuthCode{
+		ClientID:    client.ID,
+		RedirectURI: req.RedirectURI,
+		Subject:     identity.Subject,
+		Name:        identity.Name,
+		Email:       identity.Email,
+		Scopes:      scopes,
+		Challenge:   req.CodeChallenge,
+		Nonce:       req.Nonce,
+		AuthTime:    time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("CreateCode: %s", err.Error())
+		redirectError(ctx, req, codeServerError)
+		return
+	}
+
+	ctx.SetCookie(csrfCookie, "", -1, "/auth/authorize", "", ctx.Request.TLS != nil, true)
+	redirect(ctx, req, url.Values{"code": {code}})
+}
+
+// checkAuthorizeRequest validates req. Problems with the client or redirect URI
+// are shown on the page, since redirecting to an unverified URI would make the
+// endpoint an open redirector; the others are sent back to the client.
+func (h *Handler) checkAuthorizeRequest(ctx *gin.Context, req authorizeRequest) (Client, []string, bool) {
+	if h.opts.Patrons == nil {
+		renderAuthorize(ctx, http.StatusNotFound, authorizePageData{Fatal: "Patron sign-in is not enabled."})
+		return Client{}, nil, false
+	}
+
+	client, err := h.repository.FindById(ctx, req.ClientID)
+	if err != nil || client.Disabled {
+		if err != nil && !errors.Is(err, ErrClientNotFound) {
+			log.Printf("FindById: %s", err.Error())
+		}
+		renderAuthorize(ctx, http.StatusBadRequest, authorizePageData{Fatal: "Unknown application."})
+		return Client{}, nil, false
+	}
+
+	if req.RedirectURI == "" || !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		renderAuthorize(ctx, http.StatusBadRequest, authorizePageData{Fatal: "The application sent an invalid redirect URI."})
+		return Client{}, nil, false
+	}
+
+	if req.ResponseType != "code" {
+		redirectError(ctx, req, "unsupported_response_type")
+		return Client{}, nil, false
+	}
+
+	// PKCE is mandatory, with S256 only (the plain method gives no protection)
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		redirectError(ctx, req, codeInvalidRequest)
+		return Client{}, nil, false
+	}
+
+	scopes, err := grantPatronScopes(client, ParseScope(req.Scope))
+	if err != nil {
+		redirectError(ctx, req, codeInvalidScope)
+		return Client{}, nil, false
+	}
+
+	return client, scopes, true
+}
+
+// redirect sends the browser back to the client with params and the request state.
+func redirect(ctx *gin.Context, req authorizeRequest, params url.Values) {
+	u, _ := url.Parse(req.RedirectURI) // validated when the client was registered
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	u.RawQuery = q.Encode()
+
+	ctx.Redirect(http.StatusFound, u.String())
+}
+
+func redirectError(ctx *gin.Context, req authorizeRequest, code string) {
+	redirect(ctx, req, url.Values{"error": {code}})
+}
+
+func renderAuthorize(ctx *gin.Context, status int, data authorizePageData) {
+	noStore(ctx)
+	ctx.Header("X-Frame-Options", "DENY") // no clickjacking of the consent buttons
+	ctx.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(status)
+
+	if err := authorizeTemplate.Execute(ctx.Writer, data); err != nil {
+		log.Printf("authorizeTemplate.Execute: %s", err.Error())
+	}
+}
+
+func displayName(c Client) string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return c.ID
+}
+
+func describeScopes(scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if d, ok := scopeDescriptions[s]; ok {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
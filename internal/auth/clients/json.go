@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// JSON keeps the clients in a JSON file, rewritten atomically on every change.
+type JSON struct {
+	mu      sync.Mutex
+	path    string
+	clients map[string]auth.Client
+}
+
+// NewJSON loads the clients file at path, creating it with the seed clients if it doesn't exist.
+func NewJSON(path string, seed []auth.Client) (*JSON, error) {
+	j := &JSON{
+		path:    path,
+		clients: map[string]auth.Client{},
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll: %w", err)
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		for _, c := range seed {
+			j.clients[c.ID] = c
+		}
+
+		if err := j.persist(); err != nil {
+			return nil, fmt.Errorf("persist: %w", err)
+		}
+
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &j.clients); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	return j, nil
+}
+
+// persist writes the clients to a temporary file and renames it over the real one.
+// The file holds secret hashes, so it is only readable by the owner.
+func (j *JSON) persist() error {
+	tmp := j.path + ".tmp"
+
+	bytes, err := json.MarshalIndent(j.clients, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(tmp, bytes, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
+
+// FindById offers thread-safe read of a client by its id.
+func (j *JSON) FindById(ctx context.Context, clientID string) (auth.Client, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	c, ok := j.clients[clientID]
+	if !ok {
+		return auth.Client{}, auth.ErrClientNotFound
+	}
+
+	return c, nil
+}
+
+// List returns every client, sorted by id.
+func (j *JSON) List(ctx context.Context) ([]auth.Client, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return sorted(j.clients), nil
+}
+
+// Create adds a new client and persists the file.
+func (j *JSON) Create(ctx context.Context, c auth.Client) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, exists := j.clients[c.ID]; exists {
+		return auth.ErrClientDuplicate
+	}
+
+	j.clients[c.ID] = c
+	if err := j.persist(); err != nil {
+		delete(j.clients, c.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Update replaces an existing client and persists the file.
+func (j *JSON) Update(ctx context.Context, c auth.Client) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	previous, exists := j.clients[c.ID]
+	if !exists {
+		return auth.ErrClientNotFound
+	}
+
+	j.clients[c.ID] = c
+	if err := j.persist(); err != nil {
+		j.clients[c.ID] = previous
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a client and persists the file.
+func (j *JSON) Delete(ctx context.Context, clientID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	previous, exists := j.clients[clientID]
+	if !exists {
+		return auth.ErrClientNotFound
+	}
+
+	delete(j.clients, clientID)
+	if err := j.persist(); err != nil {
+		j.clients[clientID] = previous
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package clients
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/auth"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Memory keeps the clients on a map; they are lost on restart.
+type Memory struct {
+	mu      sync.RWMutex
+	clients map[string]auth.Client // key is the client id
+}
+
+// NewMemory creates a Memory repository holding the seed clients.
+func NewMemory(seed []auth.Client) *Memory {
+	m := &Memory{clients: make(map[string]auth.Client, len(seed))}
+
+	for _, c := range seed {
+		m.clients[c.ID] = c
+	}
+
+	return m
+}
+
+// FindById offers thread-safe read of a client by its id.
+func (m *Memory) FindById(ctx context.Context, clientID string) (auth.Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.clients[clientID]
+	if !ok {
+		return auth.Client{}, auth.ErrClientNotFound
+	}
+
+	return c, nil
+}
+
+// List returns every client, sorted by id.
+func (m *Memory) List(ctx context.Context) ([]auth.Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sorted(m.clients), nil
+}
+
+// Create adds a new client, failing if the id is already taken.
+func (m *Memory) Create(ctx context.Context, c auth.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[c.ID]; exists {
+		return auth.ErrClientDuplicate
+	}
+
+	m.clients[c.ID] = c
+	return nil
+}
+
+// Update replaces an existing client.
+func (m *Memory) Update(ctx context.Context, c auth.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[c.ID]; !exists {
+		return auth.ErrClientNotFound
+	}
+
+	m.clients[c.ID] = c
+	return nil
+}
+
+// Delete removes a client.
+func (m *Memory) Delete(ctx context.Context, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[clientID]; !exists {
+		return auth.ErrClientNotFound
+	}
+
+	delete(m.clients, clientID)
+	return nil
+}
+
+// sorted returns the values of clients ordered by id, so listings are stable.
+func sorted(clients map[string]auth.Client) []auth.Client {
+	out := make([]auth.Client, 0, len(clients))
+	for _, c := range clients {
+		out = append(out, c)
+	}
+
+	slices.SortFunc(out, func(a, b auth.Client) int { return strings.Compare(a.ID, b.ID) })
+	return out
+}
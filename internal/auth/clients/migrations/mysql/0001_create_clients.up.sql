@@ -0,0 +1,13 @@
+-- baseline, same as the table go-gin-library-infra/db/init.sql used to create: a no-op on those databases
+CREATE TABLE IF NOT EXISTS Clients (
+    id varchar(64) NOT NULL,
+    name varchar(255) NOT NULL DEFAULT '',
+    secret_hash varchar(255) NOT NULL,
+    created_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    disabled boolean NOT NULL DEFAULT false,
+    scopes varchar(1024) NOT NULL DEFAULT '',
+    redirect_uris varchar(2048) NOT NULL DEFAULT '',
+    tls_subject varchar(512) NOT NULL DEFAULT '',
+    tls_sans varchar(2048) NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+);
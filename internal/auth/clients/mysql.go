@@ -0,0 +1,211 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/migrate"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDupEntry is the MySQL error number for a duplicate primary key.
+const errDupEntry = 1062
+
+// MySQL keeps the clients in the Clients table, created by Migrator.
+type MySQL struct {
+	DB *sql.DB
+}
+
+// NewMySQL connects to the database and inserts the seed clients that don't
+// exist yet, which needs the table: on a new database, pass no seed and call
+// Seed once Migrator created it.
+func NewMySQL(dsn string, seed []auth.Client) (*MySQL, error) {
+	dsnCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql.ParseDSN: %w", err)
+	}
+	dsnCfg.ParseTime = true       // created_at is scanned into a time.Time
+	dsnCfg.ClientFoundRows = true // UPDATE reports matched rows, not only changed ones
+
+	db, err := sql.Open("mysql", dsnCfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.Ping: %w", err)
+	}
+
+	s := &MySQL{
+		DB: db,
+	}
+
+	if err := s.Seed(context.Background(), seed); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Seed inserts the clients that don't exist yet.
+func (s *MySQL) Seed(ctx context.Context, seed []auth.Client) error {
+	for _, c := range seed {
+		if err := s.insertIgnore(ctx, c); err != nil {
+			return fmt.Errorf("insertIgnore: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrations holds the schema of the Clients table. Add a new numbered file to
+// change it; never edit an applied one.
+//
+//go:embed migrations/mysql
+var migrations embed.FS
+
+// Migrator applies the schema migrations of migrations/mysql, recorded in schema_migrations_clients
+// so they don't clash with those of the other tables.
+func (s *MySQL) Migrator() (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations/mysql")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithHistory(s.DB, migrate.MySQL, sub, "schema_migrations_clients")
+}
+
+// insertIgnore inserts a client, leaving any existing client with the same id untouched.
+func (s *MySQL) insertIgnore(ctx context.Context, c auth.Client) error {
+	const q = `INSERT IGNORE INTO Clients (id, name, secret_hash, created_at, disabled, scopes, redirect_uris, tls_subject, tls_sans)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	_, err := s.DB.ExecContext(ctx, q, c.ID, c.Name, c.SecretHash, c.CreatedAt, c.Disabled, strings.Join(c.Scopes, " "), strings.Join(c.RedirectURIs, " "), c.TLSSubject, strings.Join(c.TLSSANs, " "))
+	return err
+}
+
+// FindById queries a client by its id.
+func (s *MySQL) FindById(ctx context.Context, clientID string) (auth.Client, error) {
+	const q = `SELECT id, name, secret_hash, created_at, disabled, scopes, redirect_uris, tls_subject, tls_sans FROM Clients WHERE id=?;`
+
+	c, err := scanClient(s.DB.QueryRowContext(ctx, q, clientID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Client{}, auth.ErrClientNotFound
+	}
+
+	if err != nil {
+		return auth.Client{}, err
+	}
+
+	return c, nil
+}
+
+// List returns every client, sorted by id.
+func (s *MySQL) List(ctx context.Context) ([]auth.Client, error) {
+	const q = `SELECT id, name, secret_hash, created_at, disabled, scopes, redirect_uris, tls_subject, tls_sans FROM Clients
+				ORDER BY id;`
+
+	rows, err := s.DB.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []auth.Client{}
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+// Create inserts a new client, failing with ErrClientDuplicate if the id is taken.
+func (s *MySQL) Create(ctx context.Context, c auth.Client) error {
+	const q = `INSERT INTO Clients (id, name, secret_hash, created_at, disabled, scopes, redirect_uris, tls_subject, tls_sans)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	_, err := s.DB.ExecContext(ctx, q, c.ID, c.Name, c.SecretHash, c.CreatedAt, c.Disabled, strings.Join(c.Scopes, " "), strings.Join(c.RedirectURIs, " "), c.TLSSubject, strings.Join(c.TLSSANs, " "))
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDupEntry {
+		return auth.ErrClientDuplicate
+	}
+
+	return err
+}
+
+// Update replaces the name, secret hash and disabled flag of an existing client.
+func (s *MySQL) Update(ctx context.Context, c auth.Client) error {
+	const q = `UPDATE Clients
+				SET name=?, secret_hash=?, disabled=?, scopes=?, redirect_uris=?, tls_subject=?, tls_sans=?
+				WHERE id=?;`
+
+	res, err := s.DB.ExecContext(ctx, q, c.Name, c.SecretHash, c.Disabled, strings.Join(c.Scopes, " "), strings.Join(c.RedirectURIs, " "), c.TLSSubject, strings.Join(c.TLSSANs, " "), c.ID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(res)
+}
+
+// Delete removes a client.
+func (s *MySQL) Delete(ctx context.Context, clientID string) error {
+	const q = `DELETE FROM Clients WHERE id=?;`
+
+	res, err := s.DB.ExecContext(ctx, q, clientID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(res)
+}
+
+// scanClient reads one client row; scopes, redirect URIs and SANs are stored space-delimited.
+func scanClient(row interface{ Scan(dest ...any) error }) (auth.Client, error) {
+	var c auth.Client
+	var scopes, redirectURIs, sans string
+	if err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &c.CreatedAt, &c.Disabled, &scopes, &redirectURIs, &c.TLSSubject, &sans); err != nil {
+		return auth.Client{}, err
+	}
+
+	c.Scopes = auth.ParseScope(scopes)
+	c.RedirectURIs = strings.Fields(redirectURIs)
+	c.TLSSANs = strings.Fields(sans)
+	return c, nil
+}
+
+// requireOneRow turns a statement that matched no row into ErrClientNotFound.
+func requireOneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return auth.ErrClientNotFound
+	}
+
+	return nil
+}
+
+// Close closes the underlying connection pool.
+func (s *MySQL) Close() error {
+	return s.DB.Close()
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *MySQL) Check(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
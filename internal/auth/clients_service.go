@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/audit"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions recorded by ClientService.
+const (
+	ActionClientCreated  = "client.created"
+	ActionClientDisabled = "client.disabled"
+	ActionClientEnabled  = "client.enabled"
+	ActionClientDeleted  = "client.deleted"
+	ActionSecretRotated  = "client.secret_rotated"
+	ActionScopesUpdated  = "client.scopes_updated"
+)
+
+// secretBytes is the entropy of generated client secrets.
+const secretBytes = 32
+
+// ClientService manages the registered clients. Every change is recorded on the
+// audit log with the id of the client that made it (the actor). The change is
+// already stored by then, so a failed record is logged rather than returned:
+// reporting an error would throw away a secret that is in effect.
+type ClientService struct {
+	store ClientStore
+	audit AuditLog
+}
+
+func NewClientService(store ClientStore, audit AuditLog) *ClientService {
+	s := ClientService{
+		store: store,
+		audit: audit,
+	}
+
+	return &s
+}
+
+// List returns every registered client.
+func (s *ClientService) List(ctx context.Context) ([]Client, error) {
+	out, err := s.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("store.List: %w", err)
+	}
+
+	return out, nil
+}
+
+// Create registers a new client and returns it with its generated plain-text secret.
+func (s *ClientService) Create(ctx context.Context, actor string, request ClientRequest) (Client, string, error) {
+	if err := ValidateScopes(request.Scopes); err != nil {
+		return Client{}, "", err
+	}
+
+	if err := ValidateRedirectURIs(request.RedirectURIs); err != nil {
+		return Client{}, "", err
+	}
+
+	id := request.ID
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	plain, hash, err := newSecret()
+	if err != nil {
+		return Client{}, "", err
+	}
+
+	c := Client{ID: id, Name: request.Name, SecretHash: hash, CreatedAt: time.Now().UTC(), Scopes: request.Scopes, RedirectURIs: request.RedirectURIs,
+		TLSSubject: request.TLSSubject, TLSSANs: request.TLSSANs}
+	if err := s.store.Create(ctx, c); err != nil {
+		return Client{}, "", fmt.Errorf("store.Create: %w", err)
+	}
+
+	s.record(ctx, actor, ActionClientCreated, id)
+	return c, plain, nil
+}
+
+// SetDisabled disables or re-enables a client. Disabled clients can't get new tokens.
+func (s *ClientService) SetDisabled(ctx context.Context, actor, clientID string, disabled bool) (Client, error) {
+	c, err := s.store.FindById(ctx, clientID)
+	if err != nil {
+		return Client{}, fmt.Errorf("store.FindById: %w", err)
+	}
+
+	c.Disabled = disabled
+	if err := s.store.Update(ctx, c); err != nil {
+		return Client{}, fmt.Errorf("store.Update: %w", err)
+	}
+
+	action := ActionClientEnabled
+	if disabled {
+		action = ActionClientDisabled
+	}
+
+	s.record(ctx, actor, action, clientID)
+	return c, nil
+}
+
+// SetScopes replaces the scopes a client can be granted.
+func (s *ClientService) SetScopes(ctx context.Context, actor, clientID string, scopes []string) (Client, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return Client{}, err
+	}
+
+	c, err := s.store.FindById(ctx, clientID)
+	if err != nil {
+		return Client{}, fmt.Errorf("store.FindById: %w", err)
+	}
+
+	c.Scopes = scopes
+	if err := s.store.Update(ctx, c); err != nil {
+		return Client{}, fmt.Errorf("store.Update: %w", err)
+	}
+
+	s.record(ctx, actor, ActionScopesUpdated, clientID)
+	return c, nil
+}
+
+// Delete removes a client.
+func (s *ClientService) Delete(ctx context.Context, actor, clientID string) error {
+	if err := s.store.Delete(ctx, clientID); err != nil {
+		return fmt.Errorf("store.Delete: %w", err)
+	}
+
+	s.record(ctx, actor, ActionClientDeleted, clientID)
+	return nil
+}
+
+// RotateSecret replaces the secret of a client and returns the new plain-text one.
+// The previous secret stops working immediately.
+func (s *ClientService) RotateSecret(ctx context.Context, actor, clientID string) (Client, string, error) {
+	c, err := s.store.FindById(ctx, clientID)
+	if err != nil {
+		return Client{}, "", fmt.Errorf("store.FindById: %w", err)
+	}
+
+	plain, hash, err := newSecret()
+	if err != nil {
+		return Client{}, "", err
+	}
+
+	c.SecretHash = hash
+	if err := s.store.Update(ctx, c); err != nil {
+		return Client{}, "", fmt.Errorf("store.Update: %w", err)
+	}
+
+	s.record(ctx, actor, ActionSecretRotated, clientID)
+	return c, plain, nil
+}
+
+// record appends an entry to the audit log.
+func (s *ClientService) record(ctx context.Context, actor, action, clientID string) {
+	e := audit.Event{Actor: actor, Action: action, Target: clientID}
+	if err := s.audit.Record(ctx, e); err != nil {
+		log.Printf("audit.Record %s %s: %s", action, clientID, err.Error())
+	}
+}
+
+// newSecret generates a random secret and its hash.
+func newSecret() (string, string, error) {
+	plain, err := secret.Generate(secretBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("secret.Generate: %w", err)
+	}
+
+	hash, err := secret.Hash(plain)
+	if err != nil {
+		return "", "", fmt.Errorf("secret.Hash: %w", err)
+	}
+
+	return plain, hash, nil
+}
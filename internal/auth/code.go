@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// codeTTL is how long an authorization code can wait to be exchanged.
+const codeTTL = time.Minute
+
+// CreateCode stores code under a new random value and returns it, to be sent
+// to the client's redirect URI.
+func (s *JwtService) CreateCode(ctx context.Context, code AuthCode) (string, error) {
+	plain, err := secret.Generate(32)
+	if err != nil {
+		return "", fmt.Errorf("secret.Generate: %w", err)
+	}
+
+	code.Hash = HashToken(plain)
+	code.ExpiresAt = time.Now().Add(codeTTL)
+	if err := s.opts.Codes.SaveCode(ctx, code); err != nil {
+		return "", fmt.Errorf("Codes.SaveCode: %w", err)
+	}
+
+	return plain, nil
+}
+
+// ExchangeCode redeems an authorization code for tokens issued to the patron
+// who approved it. The code is consumed even when the exchange fails, and the
+// PKCE verifier must match the challenge sent to the authorization endpoint.
+func (s *JwtService) ExchangeCode(ctx context.Context, clientID, code, redirectURI, verifier string) (TokenRes, error) {
+	ac, err := s.opts.Codes.ConsumeCode(ctx, HashToken(code))
+	if errors.Is(err, ErrCodeNotFound) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return TokenRes{}, fmt.Errorf("Codes.ConsumeCode: %w", err)
+	}
+
+	if ac.ClientID != clientID || ac.RedirectURI != redirectURI || time.Now().After(ac.ExpiresAt) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	if !verifyPKCE(ac.Challenge, verifier) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	res, err := s.issue(ctx, clientID, ac.Subject, ac.Scopes, uuid.NewString())
+	if err != nil {
+		return TokenRes{}, err
+	}
+
+	if slices.Contains(ac.Scopes, ScopeOpenID) {
+		if res.IDToken, err = s.idToken(ac); err != nil {
+			return TokenRes{}, fmt.Errorf("idToken: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// idToken signs the OpenID Connect ID token of an exchanged code.
+func (s *JwtService) idToken(ac AuthCode) (string, error) {
+	now := time.Now()
+	claims := &IDClaims{
+		Nonce:    ac.Nonce,
+		AuthTime: ac.AuthTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   ac.Subject,
+			Audience:  []string{ac.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.opts.AccessTTL)),
+		},
+	}
+
+	if slices.Contains(ac.Scopes, ScopeProfile) {
+		claims.Name, claims.Email = ac.Name, ac.Email
+	}
+
+	return s.sign(claims)
+}
+
+// verifyPKCE checks a code_verifier against its S256 code_challenge (RFC 7636).
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// ValidateRedirectURIs returns ErrInvalidRedirectURI unless every URI is an
+// absolute http(s) URL without fragment (RFC 6749 section 3.1.2).
+func ValidateRedirectURIs(uris []string) error {
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Fragment != "" {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	return nil
+}
@@ -3,8 +3,22 @@ package auth
 import "fmt"
 
 var (
-	ErrInvalidAuthType    = fmt.Errorf("unsupported authentication type")
-	ErrInvalidRequest     = fmt.Errorf("missing required fields client_id and client_secret")
+	ErrInvalidAuthType    = fmt.Errorf("unsupported grant type")
+	ErrInvalidGrant       = fmt.Errorf("authorization code or refresh token is invalid, expired or revoked")
+	ErrRefreshNotFound    = fmt.Errorf("refresh token not found")
+	ErrRefreshReused      = fmt.Errorf("refresh token reused")
+	ErrTokenRevoked       = fmt.Errorf("token has been revoked")
+	ErrInvalidRequest     = fmt.Errorf("missing client credentials")
+	ErrMissingParameter   = fmt.Errorf("missing required parameter")
+	ErrMultipleClientAuth = fmt.Errorf("client credentials sent both in the Authorization header and the body")
 	ErrInvalidCredentials = fmt.Errorf("invalid credentials")
 	ErrOnTokenIssue       = fmt.Errorf("could not issue token")
+	ErrNoSigningKey       = fmt.Errorf("no signing key loaded")
+	ErrClientNotFound     = fmt.Errorf("client not found")
+	ErrClientDuplicate    = fmt.Errorf("client already exists")
+	ErrInvalidScope       = fmt.Errorf("unknown scope or scope not granted to the client")
+	ErrInsufficientScope  = fmt.Errorf("insufficient_scope")
+	ErrInvalidRedirectURI = fmt.Errorf("redirect URIs must be absolute http(s) URLs without fragment")
+	ErrCodeNotFound       = fmt.Errorf("authorization code not found")
+	ErrTooManyAttempts    = fmt.Errorf("too many failed attempts, retry later")
 )
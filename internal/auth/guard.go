@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Alert kinds raised by the Guard.
+const (
+	// AlertLockout: a source IP was locked out of a client after too many failures.
+	AlertLockout = "lockout"
+	// AlertClientTargeted: a client failed authentication many times across all
+	// IPs, which may be a distributed guessing attempt. Nobody is locked out for it.
+	AlertClientTargeted = "client_targeted"
+)
+
+// Alert describes suspicious authentication failures.
+type Alert struct {
+	Kind     string
+	ClientID string
+	IP       string // empty for AlertClientTargeted
+	Failures int
+	Until    time.Time // end of the lockout
+}
+
+// GuardOptions configures the Guard.
+type GuardOptions struct {
+	FreeAttempts int           // failures allowed before the backoff starts
+	BaseDelay    time.Duration // first backoff, doubled by every further failure
+	MaxDelay     time.Duration
+	LockoutAfter int           // failures that lock the IP out of the client
+	Lockout      time.Duration // length of the lockout; older failures are forgotten too
+
+	// ClientAlertAfter failures of one client within Lockout, from any IP, raise
+	// an AlertClientTargeted. Zero disables it.
+	ClientAlertAfter int
+
+	OnAlert func(Alert)
+}
+
+type failures struct {
+	count        int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// stale reports whether the failures are older than window and no longer block anything.
+func (f *failures) stale(now time.Time, window time.Duration) bool {
+	return now.Sub(f.last) >= window && !now.Before(f.blockedUntil)
+}
+
+// Guard slows down client secret guessing. Failures are counted per source IP
+// and client: after FreeAttempts each failure makes the pair wait exponentially
+// longer, and LockoutAfter failures lock it out. Since the key includes the IP,
+// an attacker can't lock a legitimate client out from elsewhere.
+type Guard struct {
+	mu        sync.Mutex
+	opts      GuardOptions
+	pairs     map[string]*failures
+	clients   map[string]*failures
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewGuard(opts GuardOptions) *Guard {
+	return &Guard{
+		opts:    opts,
+		pairs:   map[string]*failures{},
+		clients: map[string]*failures{},
+		now:     time.Now,
+	}
+}
+
+// Blocked returns how long ip must wait before trying to authenticate as clientID again.
+func (g *Guard) Blocked(ip, clientID string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.pairs[pairKey(ip, clientID)]
+	if !ok {
+		return 0
+	}
+
+	if wait := f.blockedUntil.Sub(g.now()); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// Fail records a failed authentication of ip as clientID.
+func (g *Guard) Fail(ip, clientID string) {
+	var alerts []Alert
+
+	g.mu.Lock()
+	now := g.now()
+	g.sweep(now)
+
+	f := g.entry(g.pairs, pairKey(ip, clientID), now)
+	f.count++
+	f.last = now
+
+	switch {
+	case g.opts.LockoutAfter > 0 && f.count >= g.opts.LockoutAfter:
+		f.blockedUntil = now.Add(g.opts.Lockout)
+		if f.count == g.opts.LockoutAfter {
+			alerts = append(alerts, Alert{Kind: AlertLockout, ClientID: clientID, IP: ip, Failures: f.count, Until: f.blockedUntil})
+		}
+	case f.count > g.opts.FreeAttempts:
+		f.blockedUntil = now.Add(g.backoff(f.count - g.opts.FreeAttempts))
+	}
+
+	c := g.entry(g.clients, clientID, now)
+	c.count++
+	c.last = now
+	if g.opts.ClientAlertAfter > 0 && c.count == g.opts.ClientAlertAfter {
+		alerts = append(alerts, Alert{Kind: AlertClientTargeted, ClientID: clientID, Failures: c.count})
+	}
+	g.mu.Unlock()
+
+	// hooks run outside the lock, they may be slow (webhooks, mail...)
+	if g.opts.OnAlert != nil {
+		for _, a := range alerts {
+			g.opts.OnAlert(a)
+		}
+	}
+}
+
+// Succeed clears the failures of ip as clientID.
+func (g *Guard) Succeed(ip, clientID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.pairs, pairKey(ip, clientID))
+}
+
+// backoff is the delay after the n-th failure past the free attempts.
+func (g *Guard) backoff(n int) time.Duration {
+	delay := g.opts.BaseDelay
+	for i := 1; i < n && delay < g.opts.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, g.opts.MaxDelay)
+}
+
+func (g *Guard) entry(m map[string]*failures, key string, now time.Time) *failures {
+	f, ok := m[key]
+	if !ok {
+		f = &failures{}
+		m[key] = f
+	}
+
+	if f.stale(now, g.opts.Lockout) {
+		*f = failures{}
+	}
+	return f
+}
+
+// sweep drops the entries whose failures were forgotten, at most once a minute.
+func (g *Guard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+
+	for _, m := range []map[string]*failures{g.pairs, g.clients} {
+		for key, f := range m {
+			if f.stale(now, g.opts.Lockout) {
+				delete(m, key)
+			}
+		}
+	}
+	g.lastSweep = now
+}
+
+func pairKey(ip, clientID string) string {
+	return ip + "|" + clientID
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type guardClock struct{ t time.Time }
+
+func (c *guardClock) now() time.Time { return c.t }
+
+// newTestGuard returns a Guard on a fake clock, recording its alerts.
+func newTestGuard(opts GuardOptions) (*Guard, *guardClock, *[]Alert) {
+	c := &guardClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	alerts := &[]Alert{}
+	opts.OnAlert = func(a Alert) { *alerts = append(*alerts, a) }
+
+	g := NewGuard(opts)
+	g.now = c.now
+	return g, c, alerts
+}
+
+var guardOpts = GuardOptions{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     8 * time.Second,
+	LockoutAfter: 10,
+	Lockout:      15 * time.Minute,
+}
+
+func TestGuardBackoff(t *testing.T) {
+	g, _, _ := newTestGuard(guardOpts)
+
+	tests := []struct {
+		failures int
+		blocked  time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, 0}, // the free attempts
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 8 * time.Second}, // capped at MaxDelay
+		{9, 8 * time.Second},
+		{10, 15 * time.Minute}, // locked out
+		{11, 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		g.Fail("10.0.0.1", "c1")
+		if got := g.Blocked("10.0.0.1", "c1"); got != tt.blocked {
+			t.Errorf("after %d failures Blocked = %s, want %s", tt.failures, got, tt.blocked)
+		}
+	}
+}
+
+func TestGuardLockoutIsPerIPAndClient(t *testing.T) {
+	g, _, alerts := newTestGuard(guardOpts)
+
+	for range guardOpts.LockoutAfter {
+		g.Fail("10.0.0.1", "c1")
+	}
+
+	if got := g.Blocked("10.0.0.1", "c1"); got != guardOpts.Lockout {
+		t.Fatalf("Blocked = %s, want the lockout", got)
+	}
+	if got := g.Blocked("10.0.0.2", "c1"); got != 0 {
+		t.Errorf("the client from another IP is blocked for %s", got)
+	}
+	if got := g.Blocked("10.0.0.1", "c2"); got != 0 {
+		t.Errorf("another client from the IP is blocked for %s", got)
+	}
+
+	want := Alert{Kind: AlertLockout, ClientID: "c1", IP: "10.0.0.1", Failures: guardOpts.LockoutAfter, Until: g.now().Add(guardOpts.Lockout)}
+	if len(*alerts) != 1 || (*alerts)[0] != want {
+		t.Errorf("alerts = %+v, want %+v", *alerts, want)
+	}
+
+	// failing on while locked out doesn't raise the alert again
+	g.Fail("10.0.0.1", "c1")
+	if len(*alerts) != 1 {
+		t.Errorf("%d alerts after failing during the lockout, want 1", len(*alerts))
+	}
+}
+
+func TestGuardLockoutExpires(t *testing.T) {
+	g, c, _ := newTestGuard(guardOpts)
+
+	for range guardOpts.LockoutAfter {
+		g.Fail("10.0.0.1", "c1")
+	}
+
+	c.t = c.t.Add(guardOpts.Lockout - time.Second)
+	if got := g.Blocked("10.0.0.1", "c1"); got != time.Second {
+		t.Errorf("Blocked a second before the end = %s", got)
+	}
+
+	c.t = c.t.Add(time.Second)
+	if got := g.Blocked("10.0.0.1", "c1"); got != 0 {
+		t.Fatalf("Blocked after the lockout = %s", got)
+	}
+
+	// the failures were forgotten with it: the free attempts are back
+	g.Fail("10.0.0.1", "c1")
+	if got := g.Blocked("10.0.0.1", "c1"); got != 0 {
+		t.Errorf("Blocked after one failure past the lockout = %s, want 0", got)
+	}
+}
+
+func TestGuardSucceedClears(t *testing.T) {
+	g, _, _ := newTestGuard(guardOpts)
+
+	for range guardOpts.FreeAttempts + 2 {
+		g.Fail("10.0.0.1", "c1")
+	}
+	g.Succeed("10.0.0.1", "c1")
+
+	g.Fail("10.0.0.1", "c1")
+	if got := g.Blocked("10.0.0.1", "c1"); got != 0 {
+		t.Errorf("Blocked after a success and one failure = %s, want 0", got)
+	}
+}
+
+func TestGuardClientTargetedAlert(t *testing.T) {
+	opts := guardOpts
+	opts.ClientAlertAfter = 5
+	g, c, alerts := newTestGuard(opts)
+
+	// one failure from each of many IPs: nobody is slowed down, but the client is targeted
+	for i := range 6 {
+		g.Fail(fmt.Sprintf("10.0.1.%d", i), "c1")
+	}
+
+	want := Alert{Kind: AlertClientTargeted, ClientID: "c1", Failures: 5}
+	if len(*alerts) != 1 || (*alerts)[0] != want {
+		t.Fatalf("alerts = %+v, want %+v", *alerts, want)
+	}
+
+	// the count is forgotten after Lockout, so the alert can come again
+	c.t = c.t.Add(opts.Lockout)
+	for i := range 5 {
+		g.Fail(fmt.Sprintf("10.0.2.%d", i), "c1")
+	}
+	if len(*alerts) != 2 {
+		t.Errorf("%d alerts after a second wave, want 2", len(*alerts))
+	}
+}
+
+func TestGuardWithoutLockout(t *testing.T) {
+	opts := guardOpts
+	opts.LockoutAfter = 0
+	g, _, alerts := newTestGuard(opts)
+
+	for range 20 {
+		g.Fail("10.0.0.1", "c1")
+	}
+
+	if got := g.Blocked("10.0.0.1", "c1"); got != opts.MaxDelay {
+		t.Errorf("Blocked = %s, want MaxDelay when lockouts are disabled", got)
+	}
+	if len(*alerts) != 0 {
+		t.Errorf("alerts = %+v, want none", *alerts)
+	}
+}
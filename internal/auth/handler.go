@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,45 +15,235 @@ import (
 type Handler struct {
 	repository ClientRepository
 	service    Service
+	keys       *KeySet
+	opts       HandlerOptions
+}
+
+// HandlerOptions describes the server in the authorization server metadata.
+type HandlerOptions struct {
+	Issuer        string
+	PublicURL     string // base URL of the endpoints, taken from the request when empty
+	RefreshTokens bool
+	Patrons       Authenticator // enables the authorization code flow when set
+
+	MTLS bool // client certificates are requested, enabling tls_client_auth and bound tokens
+
+	// External verifies the tokens of trusted external issuers, keyed by their iss claim.
+	External map[string]TokenVerifier
+
+	// Guard throttles failed client authentications; nil disables it.
+	Guard *Guard
+}
+
+// TokenVerifier checks a token issued by someone else and maps it to local claims.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*Claims, error)
 }
 
 // NewHandler creates the Auth endpoint for authentication request.
-func NewHandler(repository ClientRepository, service Service) *Handler {
+func NewHandler(repository ClientRepository, service Service, keys *KeySet, opts HandlerOptions) *Handler {
 	a := Handler{
 		repository: repository,
 		service:    service,
+		keys:       keys,
+		opts:       opts,
 	}
 
 	return &a
 }
 
-// RequestAuth returns a Bearer Token if credentials received on client_id and client_secret are valid.
+// RequestAuth is the RFC 6749 token endpoint. The client authenticates with HTTP
+// Basic or client_id and client_secret in the form; the client_credentials,
+// authorization_code and refresh_token grants are supported, and the optional scope
+// field narrows the token down to a subset of the client's (or refresh token's) scopes.
 func (h *Handler) RequestAuth(ctx *gin.Context) {
+	noStore(ctx)
 
 	grantType := ctx.PostForm("grant_type")
-	if grantType != "client_credentials" {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidAuthType.Error()})
+	if grantType == "" {
+		oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, fmt.Errorf("%w: grant_type", ErrMissingParameter))
 		return
 	}
 
-	clientID := ctx.PostForm("client_id")
-	clientSecret := ctx.PostForm("client_secret")
+	if grantType != GrantClientCredentials && grantType != GrantRefreshToken &&
+		(grantType != GrantAuthorizationCode || h.opts.Patrons == nil) {
+		oauthError(ctx, http.StatusBadRequest, codeUnsupportedGrantType, ErrInvalidAuthType)
+		return
+	}
 
-	if clientID == "" || clientSecret == "" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidRequest.Error()})
+	client, ok := h.authenticateClient(ctx)
+	if !ok {
 		return
 	}
 
-	if exists := h.repository.Validate(clientID, clientSecret); !exists {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidCredentials.Error()})
+	// tokens requested over mutual TLS are bound to the certificate (RFC 8705)
+	issueCtx := context.Context(ctx)
+	if cert := peerCertificate(ctx.Request); cert != nil {
+		issueCtx = withCertThumbprint(ctx, CertThumbprint(cert))
+	}
+
+	requested := ParseScope(ctx.PostForm("scope"))
+
+	var (
+		tok TokenRes
+		err error
+	)
+	switch grantType {
+	case GrantClientCredentials:
+		scopes, scopeErr := grantScopes(client, requested)
+		if scopeErr != nil {
+			oauthError(ctx, http.StatusBadRequest, codeInvalidScope, scopeErr)
+			return
+		}
+
+		tok, err = h.service.IssueToken(issueCtx, client.ID, scopes)
+	case GrantAuthorizationCode:
+		code, verifier := ctx.PostForm("code"), ctx.PostForm("code_verifier")
+		if code == "" || verifier == "" {
+			oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, fmt.Errorf("%w: code and code_verifier", ErrMissingParameter))
+			return
+		}
+
+		tok, err = h.service.ExchangeCode(issueCtx, client.ID, code, ctx.PostForm("redirect_uri"), verifier)
+	case GrantRefreshToken:
+		refresh := ctx.PostForm("refresh_token")
+		if refresh == "" {
+			oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, fmt.Errorf("%w: refresh_token", ErrMissingParameter))
+			return
+		}
+
+		tok, err = h.service.Refresh(issueCtx, client, refresh, requested)
+	}
+
+	switch {
+	case errors.Is(err, ErrInvalidGrant):
+		oauthError(ctx, http.StatusBadRequest, codeInvalidGrant, err)
+		return
+	case errors.Is(err, ErrInvalidScope):
+		oauthError(ctx, http.StatusBadRequest, codeInvalidScope, err)
+		return
+	case err != nil:
+		log.Printf("RequestAuth: %s", err.Error())
+		oauthError(ctx, http.StatusInternalServerError, codeServerError, ErrOnTokenIssue)
 		return
 	}
 
-	tok, err := h.service.IssueToken(clientID, 60*time.Minute)
+	ctx.Set("token_scope", tok.Scope) // for AuditTokens
+	ctx.IndentedJSON(http.StatusOK, tok)
+}
+
+// Revoke implements the RFC 7009 revocation endpoint. The response is 200
+// whether or not the token was known, so it can't be used to probe tokens.
+func (h *Handler) Revoke(ctx *gin.Context) {
+	noStore(ctx)
+
+	client, ok := h.authenticateClient(ctx)
+	if !ok {
+		return
+	}
+
+	token := ctx.PostForm("token")
+	if token == "" {
+		oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, fmt.Errorf("%w: token", ErrMissingParameter))
+		return
+	}
+
+	if err := h.service.Revoke(ctx, client.ID, token); err != nil {
+		log.Printf("Revoke: %s", err.Error())
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not revoke token"})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Introspect implements the RFC 7662 introspection endpoint, so resource servers
+// can check a token without verifying JWTs themselves. The caller authenticates
+// with its client credentials.
+func (h *Handler) Introspect(ctx *gin.Context) {
+	noStore(ctx)
+
+	client, ok := h.authenticateClient(ctx)
+	if !ok {
+		return
+	}
+
+	token := ctx.PostForm("token")
+	if token == "" {
+		oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, fmt.Errorf("%w: token", ErrMissingParameter))
+		return
+	}
+
+	res, err := h.service.Introspect(ctx, client.ID, token)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrOnTokenIssue.Error()})
+		log.Printf("Introspect: %s", err.Error())
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not introspect token"})
 		return
 	}
 
-	ctx.IndentedJSON(http.StatusOK, tok)
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// authenticateClient validates the client credentials of the request: its
+// secret, or with mutual TLS and no secret, its certificate. On failure the
+// response is written and false returned.
+func (h *Handler) authenticateClient(ctx *gin.Context) (Client, bool) {
+	clientID, clientSecret, err := clientCredentials(ctx)
+	if errors.Is(err, ErrMultipleClientAuth) {
+		oauthError(ctx, http.StatusBadRequest, codeInvalidRequest, err)
+		return Client{}, false
+	}
+
+	if err != nil {
+		oauthError(ctx, http.StatusUnauthorized, codeInvalidClient, err)
+		return Client{}, false
+	}
+
+	cert := peerCertificate(ctx.Request)
+	if clientID == "" || (clientSecret == "" && cert == nil) {
+		oauthError(ctx, http.StatusUnauthorized, codeInvalidClient, ErrInvalidRequest)
+		return Client{}, false
+	}
+
+	ip := ctx.ClientIP()
+	if h.opts.Guard != nil {
+		// the secret isn't even checked while blocked, so guessing doesn't get any faster
+		if wait := h.opts.Guard.Blocked(ip, clientID); wait > 0 {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			oauthError(ctx, http.StatusTooManyRequests, codeTemporarilyUnavailable, ErrTooManyAttempts)
+			return Client{}, false
+		}
+	}
+
+	var client Client
+	if clientSecret != "" {
+		client, err = ValidateClient(ctx, h.repository, clientID, clientSecret)
+	} else {
+		client, err = ValidateClientCert(ctx, h.repository, clientID, cert)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("ValidateClient: %s", err.Error())
+			oauthError(ctx, http.StatusInternalServerError, codeServerError, ErrOnTokenIssue)
+			return Client{}, false
+		}
+
+		if h.opts.Guard != nil {
+			h.opts.Guard.Fail(ip, clientID)
+		}
+		oauthError(ctx, http.StatusUnauthorized, codeInvalidClient, ErrInvalidCredentials)
+		return Client{}, false
+	}
+
+	if h.opts.Guard != nil {
+		h.opts.Guard.Succeed(ip, clientID)
+	}
+	ctx.Set("client_id", client.ID)
+	return client, true
+}
+
+// JWKS publishes the public keys tokens can be verified with (RFC 7517).
+func (h *Handler) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.keys.JWKS())
 }
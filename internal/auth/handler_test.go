@@ -0,0 +1,93 @@
+package auth_test
+
+import (
+	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/auth/clients"
+	"example/go-gin-library-api/internal/secret"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+const readerSecret = "reader-secret"
+
+// newTokenEndpoint serves POST /auth/token for the reader client, throttled by guard.
+func newTokenEndpoint(t *testing.T, guard *auth.Guard) *gin.Engine {
+	t.Helper()
+
+	hash, err := secret.Hash(readerSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := reader
+	client.SecretHash = hash
+
+	keys := auth.NewKeySet()
+	service, _ := newService(t, 0)
+	h := auth.NewHandler(clients.NewMemory([]auth.Client{client}), service, keys, auth.HandlerOptions{Guard: guard})
+
+	r := gin.New()
+	r.POST("/auth/token", h.RequestAuth)
+	return r
+}
+
+func requestToken(r http.Handler, ip, clientSecret string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {reader.ID}, "client_secret": {clientSecret}}
+	req := httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":1234"
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTokenLockoutIsScopedToIP(t *testing.T) {
+	var alerts []auth.Alert
+	guard := auth.NewGuard(auth.GuardOptions{
+		FreeAttempts: 5,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 3,
+		Lockout:      15 * time.Minute,
+		OnAlert:      func(a auth.Alert) { alerts = append(alerts, a) },
+	})
+	r := newTokenEndpoint(t, guard)
+
+	for i := range 3 {
+		if w := requestToken(r, "203.0.113.7", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong secret %d: %d, want 401", i+1, w.Code)
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Kind != auth.AlertLockout || alerts[0].IP != "203.0.113.7" {
+		t.Fatalf("alerts = %+v, want a lockout of 203.0.113.7", alerts)
+	}
+
+	// the attacker's IP is locked out, even with the right secret
+	w := requestToken(r, "203.0.113.7", readerSecret)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked out IP: %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "900" {
+		t.Errorf("Retry-After = %q, want 900", got)
+	}
+
+	// the client itself, elsewhere, isn't
+	w = requestToken(r, "198.51.100.20", readerSecret)
+	if w.Code != http.StatusOK {
+		t.Fatalf("client from another IP during the lockout: %d %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"access_token"`) {
+		t.Errorf("no access token in %s", w.Body)
+	}
+}
@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported by the KeySet.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is one key of the KeySet. Private is []byte for HS256 and a
+// crypto.Signer for the asymmetric algorithms.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   any
+	CreatedAt time.Time
+
+	retireAt time.Time // zero while the key is active or kept forever
+}
+
+// Public returns the key used to verify signatures.
+func (k *SigningKey) Public() any {
+	if signer, ok := k.Private.(crypto.Signer); ok {
+		return signer.Public()
+	}
+
+	return k.Private // HS256 verifies with the shared secret
+}
+
+// Method returns the jwt signing method matching the key algorithm.
+func (k *SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeySet holds the active signing key and the previous keys still accepted
+// for verification until they retire.
+type KeySet struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey // kid -> key
+	reload func() bool            // reloads a shared key directory, reporting whether it did
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{keys: map[string]*SigningKey{}}
+}
+
+// Add makes k available for verification, and for signing if activate is set.
+func (s *KeySet) Add(k *SigningKey, activate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = k
+	if activate {
+		s.active = k
+	}
+}
+
+// AddRetiring makes k available for verification only, until retireAt.
+func (s *KeySet) AddRetiring(k *SigningKey, retireAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k.retireAt = retireAt
+	s.keys[k.ID] = k
+}
+
+// Rotate activates next; the previous active key keeps validating tokens for
+// overlap (at least the lifetime of the tokens it signed) and is then dropped.
+// The ids of the keys dropped by this call are returned.
+func (s *KeySet) Rotate(next *SigningKey, overlap time.Duration) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.activate(next, now.Add(overlap))
+
+	return s.prune(now)
+}
+
+// Load merges the keys of a shared directory, oldest first, written by other
+// instances: those the set doesn't hold verify until their successor has been
+// active for overlap, and the newest one is activated if it is newer than the
+// active key. The ids of the keys dropped by this call are returned.
+func (s *KeySet) Load(loaded []*SigningKey, overlap time.Duration) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i, k := range loaded[:max(len(loaded)-1, 0)] {
+		retireAt := loaded[i+1].CreatedAt.Add(overlap)
+		if _, known := s.keys[k.ID]; !known && now.Before(retireAt) {
+			k.retireAt = retireAt
+			s.keys[k.ID] = k
+		}
+	}
+
+	if len(loaded) > 0 {
+		newest := loaded[len(loaded)-1]
+
+		switch known, ok := s.keys[newest.ID]; {
+		case ok && known == s.active:
+		case s.active == nil || newest.CreatedAt.After(s.active.CreatedAt):
+			if ok {
+				newest = known
+			}
+			s.activate(newest, now.Add(overlap))
+		case !ok && now.Before(s.active.CreatedAt.Add(overlap)):
+			// older than the active key, dropped in by hand
+			newest.retireAt = s.active.CreatedAt.Add(overlap)
+			s.keys[newest.ID] = newest
+		}
+	}
+
+	return s.prune(now)
+}
+
+// activate makes next the signing key, the previous one retiring at retireAt.
+// It must be called with the lock held.
+func (s *KeySet) activate(next *SigningKey, retireAt time.Time) {
+	if s.active != nil && s.active.ID != next.ID {
+		s.active.retireAt = retireAt
+	}
+
+	next.retireAt = time.Time{}
+	s.keys[next.ID] = next
+	s.active = next
+}
+
+// prune drops the keys whose overlap ended. It must be called with the lock held.
+func (s *KeySet) prune(now time.Time) []string {
+	var dropped []string
+	for id, k := range s.keys {
+		if !k.retireAt.IsZero() && now.After(k.retireAt) {
+			delete(s.keys, id)
+			dropped = append(dropped, id)
+		}
+	}
+
+	return dropped
+}
+
+// Active returns the key new tokens are signed with.
+func (s *KeySet) Active() (*SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.active == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return s.active, nil
+}
+
+// Lookup returns the key identified by kid if it hasn't retired. An unknown kid
+// may have been written by another instance sharing the key directory, which
+// the set reloads once before giving up.
+func (s *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	if k, ok := s.lookup(kid); ok {
+		return k, true
+	}
+
+	s.mu.RLock()
+	reload := s.reload
+	s.mu.RUnlock()
+
+	if reload == nil || !reload() {
+		return nil, false
+	}
+
+	return s.lookup(kid)
+}
+
+func (s *KeySet) lookup(kid string) (*SigningKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[kid]
+	if !ok || (!k.retireAt.IsZero() && time.Now().After(k.retireAt)) {
+		return nil, false
+	}
+
+	return k, true
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys currently accepted for verification. HS256 keys
+// are never published, they are secrets.
+func (s *KeySet) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for _, k := range s.keys {
+		if !k.retireAt.IsZero() && now.After(k.retireAt) {
+			continue
+		}
+
+		if jwk, err := publicJWK(k.Public()); err == nil {
+			jwk.Kid, jwk.Use, jwk.Alg = k.ID, "sig", k.Algorithm
+			out.Keys = append(out.Keys, jwk)
+		}
+	}
+
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Kid < out.Keys[j].Kid })
+	return out
+}
+
+// publicJWK encodes the public part of an asymmetric key, without kid, use and alg.
+func publicJWK(pub any) (JWK, error) {
+	b64 := base64.RawURLEncoding
+
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64.EncodeToString(p.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(p.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (p.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: p.Curve.Params().Name, X: b64.EncodeToString(p.X.FillBytes(make([]byte, size))), Y: b64.EncodeToString(p.Y.FillBytes(make([]byte, size)))}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64.EncodeToString(p)}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key %T", pub)
+	}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of a public key, used as kid
+// so the same key always gets the same id, whichever instance loaded it.
+func thumbprint(pub any) (string, error) {
+	jwk, err := publicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+
+	// required members only, in lexicographic order
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	bytes, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(bytes)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewSigningKey wraps a private key, deriving its id and, for asymmetric keys,
+// checking it matches the algorithm.
+func NewSigningKey(alg string, private any) (*SigningKey, error) {
+	k := &SigningKey{Algorithm: alg, Private: private, CreatedAt: time.Now().UTC()}
+
+	switch p := private.(type) {
+	case []byte:
+		if alg != AlgHS256 {
+			return nil, fmt.Errorf("a shared secret can't be used with %s", alg)
+		}
+
+		// the secret itself must never leak through the kid
+		sum := sha256.Sum256(append([]byte("kid:"), p...))
+		k.ID = "hs-" + base64.RawURLEncoding.EncodeToString(sum[:8])
+		return k, nil
+	case *rsa.PrivateKey:
+		if alg != AlgRS256 {
+			return nil, fmt.Errorf("an RSA key can't be used with %s", alg)
+		}
+	case *ecdsa.PrivateKey:
+		if alg != AlgES256 || p.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("an ECDSA %s key can't be used with %s", p.Curve.Params().Name, alg)
+		}
+	case ed25519.PrivateKey:
+		if alg != AlgEdDSA {
+			return nil, fmt.Errorf("an Ed25519 key can't be used with %s", alg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key %T", private)
+	}
+
+	kid, err := thumbprint(k.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	k.ID = kid
+	return k, nil
+}
+
+// GenerateSigningKey creates a new random key for an asymmetric algorithm.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var (
+		private any
+		err     error
+	)
+
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("can't generate keys for %q", alg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(alg, private)
+}
+
+// AlgorithmFor returns the algorithm matching a private key type.
+func AlgorithmFor(private any) (string, error) {
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		return AlgRS256, nil
+	case *ecdsa.PrivateKey:
+		return AlgES256, nil
+	case ed25519.PrivateKey:
+		return AlgEdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported private key %T", private)
+	}
+}
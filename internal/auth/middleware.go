@@ -1,10 +1,12 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // RequireAuth ensures requests carry a valid bearer token before reaching the
@@ -19,13 +21,40 @@ func (h *Handler) RequireAuth() gin.HandlerFunc {
 		}
 
 		token := strings.TrimPrefix(header, "Bearer ")
-		claims, err := h.service.ParseAndValidate(token)
+		claims, err := h.verify(ctx, token)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
+		// a certificate-bound token is only accepted over a connection presenting that certificate
+		if claims.Cnf != nil {
+			cert := peerCertificate(ctx.Request)
+			if cert == nil || CertThumbprint(cert) != claims.Cnf.X5tS256 {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrCertificateMismatch.Error()})
+				return
+			}
+		}
+
 		ctx.Set("client_id", claims.ClientID)
+		ctx.Set("sub", claims.Subject) // the patron, empty for client credentials tokens
+		ctx.Set("scopes", ParseScope(claims.Scope))
 		ctx.Next()
 	}
 }
+
+// verify hands tokens of a configured external issuer to its verifier and
+// everything else to our own service. The issuer is read before the signature is
+// checked only to pick the verifier, which then checks everything.
+func (h *Handler) verify(ctx context.Context, token string) (*Claims, error) {
+	if len(h.opts.External) > 0 {
+		var unverified jwt.RegisteredClaims
+		if _, _, err := jwt.NewParser().ParseUnverified(token, &unverified); err == nil && unverified.Issuer != h.opts.Issuer {
+			if v, ok := h.opts.External[unverified.Issuer]; ok {
+				return v.Verify(ctx, token)
+			}
+		}
+	}
+
+	return h.service.ParseAndValidate(ctx, token)
+}
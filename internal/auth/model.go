@@ -1,20 +1,116 @@
 package auth
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Client is a registered OAuth client. Only the hash of its secret is stored.
+type Client struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"secret_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	Disabled   bool      `json:"disabled"`
+	Scopes     []string  `json:"scopes"` // the most a token of this client can be granted
+
+	// RedirectURIs are where patrons may be sent back to with an authorization code, matched exactly.
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+
+	// TLSSubject and TLSSANs let the client authenticate with a certificate
+	// instead of its secret (RFC 8705 tls_client_auth): the certificate must
+	// have this subject DN or one of these DNS, URI, email or IP SANs.
+	TLSSubject string   `json:"tls_subject,omitempty"`
+	TLSSANs    []string `json:"tls_sans,omitempty"`
+}
+
+// ClientRequest creates a client; the id is generated when left empty.
+type ClientRequest struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name" binding:"required"`
+	Scopes       []string `json:"scopes"`
+	RedirectURIs []string `json:"redirect_uris"`
+	TLSSubject   string   `json:"tls_subject"`
+	TLSSANs      []string `json:"tls_sans"`
+}
+
+// ScopesRequest replaces the scopes of a client.
+type ScopesRequest struct {
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// ClientResponse is the public view of a client, it never includes the secret hash.
+type ClientResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	Disabled     bool      `json:"disabled"`
+	Scopes       []string  `json:"scopes"`
+	RedirectURIs []string  `json:"redirect_uris,omitempty"`
+	TLSSubject   string    `json:"tls_subject,omitempty"`
+	TLSSANs      []string  `json:"tls_sans,omitempty"`
+}
+
+// ClientSecretResponse is returned when a secret is generated, the only time it is ever shown.
+type ClientSecretResponse struct {
+	ClientResponse
+	ClientSecret string `json:"client_secret"`
+}
 
 type TokenRes struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int64  `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"` // OpenID Connect, when the openid scope was granted
+}
+
+// IntrospectionRes is the RFC 7662 answer about a token. Inactive tokens only
+// carry Active, nothing is disclosed about them.
+type IntrospectionRes struct {
+	Active    bool          `json:"active"`
+	ClientID  string        `json:"client_id,omitempty"`
+	Sub       string        `json:"sub,omitempty"`
+	Scope     string        `json:"scope,omitempty"`
+	TokenType string        `json:"token_type,omitempty"` // access_token or refresh_token
+	Exp       int64         `json:"exp,omitempty"`
+	Iat       int64         `json:"iat,omitempty"`
+	Iss       string        `json:"iss,omitempty"`
+	Aud       []string      `json:"aud,omitempty"`
+	Jti       string        `json:"jti,omitempty"`
+	Cnf       *Confirmation `json:"cnf,omitempty"`
+}
+
+// Confirmation binds a token to the client certificate it was issued over (RFC 8705).
+type Confirmation struct {
+	X5tS256 string `json:"x5t#S256"` // base64url SHA-256 of the DER certificate
 }
 
 type Claims struct {
-	ClientID             string `json:"cid"`
-	jwt.RegisteredClaims        // embedded field of RegisteredClaims inside my struct
+	ClientID             string        `json:"cid"`
+	Scope                string        `json:"scope,omitempty"` // space-delimited granted scopes
+	Cnf                  *Confirmation `json:"cnf,omitempty"`   // set on certificate-bound tokens
+	jwt.RegisteredClaims               // embedded field of RegisteredClaims inside my struct; sub is the patron, if any
+}
+
+// IDClaims are the claims of an OpenID Connect ID token. Its audience is the
+// client, so it can't be mistaken for an access token of the API.
+type IDClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time"`
+	Name     string `json:"name,omitempty"`  // with the profile scope
+	Email    string `json:"email,omitempty"` // with the profile scope
+	jwt.RegisteredClaims
 }
 
 /*
 	{
 	"cid": "frontend",
+	"scope": "books:read circulation",
+	"sub": "6d1f2c3e-...",   (only for tokens issued to a patron)
+	"jti": "4f0c6c1e-5d2b-4f4e-9d8a-0f6f3f1c2b7a",
 	"exp": 1730490000,
 	"iss": "go-gin-library-api"
 	}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// AuthMethodTLS is RFC 8705 PKI mutual-TLS client authentication.
+const AuthMethodTLS = "tls_client_auth"
+
+var ErrCertificateMismatch = errors.New("token is bound to another client certificate")
+
+// peerCertificate returns the client certificate of the request, if the TLS
+// handshake verified one against the client CAs.
+func peerCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+
+	return r.TLS.PeerCertificates[0]
+}
+
+// CertThumbprint is the x5t#S256 of a certificate (RFC 8705 section 3.1).
+func CertThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// certMatches tells whether cert is the one registered for the client, by
+// subject DN or by any of its SANs.
+func certMatches(c Client, cert *x509.Certificate) bool {
+	if c.TLSSubject != "" && cert.Subject.String() == c.TLSSubject {
+		return true
+	}
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+
+	for _, san := range sans {
+		if slices.Contains(c.TLSSANs, san) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateClientCert returns the client identified by clientID if cert is
+// registered for it and the client is enabled. Failures are reported as
+// ErrInvalidCredentials, like ValidateClient.
+func ValidateClientCert(ctx context.Context, repository ClientRepository, clientID string, cert *x509.Certificate) (Client, error) {
+	client, err := repository.FindById(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return Client{}, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return Client{}, fmt.Errorf("repository.FindById: %w", err)
+	}
+
+	if client.Disabled || !certMatches(client, cert) {
+		return Client{}, ErrInvalidCredentials
+	}
+
+	return client, nil
+}
+
+type thumbprintKey struct{}
+
+// withCertThumbprint makes the tokens issued with ctx bound to the certificate.
+func withCertThumbprint(ctx context.Context, thumbprint string) context.Context {
+	return context.WithValue(ctx, thumbprintKey{}, thumbprint)
+}
+
+func certThumbprintFrom(ctx context.Context) string {
+	t, _ := ctx.Value(thumbprintKey{}).(string)
+	return t
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of the token endpoint (RFC 6749 section 5.2), also used by the
+// revocation and introspection endpoints.
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeInvalidClient        = "invalid_client"
+	codeInvalidGrant         = "invalid_grant"
+	codeUnsupportedGrantType = "unsupported_grant_type"
+	codeInvalidScope         = "invalid_scope"
+	codeServerError          = "server_error"
+
+	// codeTemporarilyUnavailable comes from the authorization endpoint (RFC 6749
+	// section 4.1.2.1); it tells a throttled client to come back later.
+	codeTemporarilyUnavailable = "temporarily_unavailable"
+)
+
+// Grant types accepted by the token endpoint.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Client authentication methods (RFC 8414 token_endpoint_auth_methods_supported).
+const (
+	AuthMethodBasic = "client_secret_basic"
+	AuthMethodPost  = "client_secret_post"
+)
+
+// noStore keeps tokens and credentials out of any cache (RFC 6749 section 5.1).
+func noStore(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+}
+
+// oauthError writes an RFC 6749 error response. err becomes the
+// error_description and is attached to the context for the metrics.
+func oauthError(ctx *gin.Context, status int, code string, err error) {
+	_ = ctx.Error(err)
+	noStore(ctx)
+
+	// a client that tried HTTP Basic is told to retry with it (RFC 6749 section 5.2)
+	if code == codeInvalidClient {
+		if _, _, basic := ctx.Request.BasicAuth(); basic {
+			ctx.Header("WWW-Authenticate", `Basic realm="token"`)
+		}
+	}
+
+	ctx.AbortWithStatusJSON(status, gin.H{"error": code, "error_description": err.Error()})
+}
+
+// clientCredentials reads the client id and secret from the Authorization
+// header (client_secret_basic) or the form (client_secret_post). Using both at
+// once is rejected, as RFC 6749 section 2.3 requires.
+func clientCredentials(ctx *gin.Context) (id, secret string, err error) {
+	user, pass, basic := ctx.Request.BasicAuth()
+	formID, formSecret := ctx.PostForm("client_id"), ctx.PostForm("client_secret")
+
+	if !basic {
+		return formID, formSecret, nil
+	}
+
+	if formSecret != "" {
+		return "", "", ErrMultipleClientAuth
+	}
+
+	// Basic credentials are form-urlencoded before being base64 encoded (RFC 6749 section 2.3.1)
+	if id, err = url.QueryUnescape(user); err != nil {
+		return "", "", ErrInvalidCredentials
+	}
+	if secret, err = url.QueryUnescape(pass); err != nil {
+		return "", "", ErrInvalidCredentials
+	}
+
+	if formID != "" && formID != id {
+		return "", "", ErrInvalidCredentials
+	}
+
+	return id, secret, nil
+}
+
+// ServerMetadata is the RFC 8414 authorization server metadata document.
+type ServerMetadata struct {
+	Issuer                                    string   `json:"issuer"`
+	AuthorizationEndpoint                     string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                             string   `json:"token_endpoint"`
+	JWKSURI                                   string   `json:"jwks_uri"`
+	RevocationEndpoint                        string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint"`
+	ScopesSupported                           []string `json:"scopes_supported"`
+	ResponseTypesSupported                    []string `json:"response_types_supported"`
+	GrantTypesSupported                       []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported"`
+	RevocationEndpointAuthMethodsSupported    []string `json:"revocation_endpoint_auth_methods_supported"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported             []string `json:"code_challenge_methods_supported,omitempty"`
+	TLSClientCertificateBoundAccessTokens     bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// Metadata publishes the authorization server metadata. Endpoint URLs are built
+// from the configured public URL, or from the request when it isn't set.
+func (h *Handler) Metadata(ctx *gin.Context) {
+	base := h.opts.PublicURL
+	if base == "" {
+		scheme := "http"
+		if ctx.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + ctx.Request.Host
+	}
+
+	grants := []string{GrantClientCredentials}
+	if h.opts.RefreshTokens {
+		grants = append(grants, GrantRefreshToken)
+	}
+
+	methods := []string{AuthMethodBasic, AuthMethodPost}
+	if h.opts.MTLS {
+		methods = append(methods, AuthMethodTLS)
+	}
+	meta := ServerMetadata{
+		Issuer:                                 h.opts.Issuer,
+		TokenEndpoint:                          base + "/auth/token",
+		JWKSURI:                                base + "/.well-known/jwks.json",
+		RevocationEndpoint:                     base + "/auth/revoke",
+		IntrospectionEndpoint:                  base + "/auth/introspect",
+		ScopesSupported:                        KnownScopes,
+		ResponseTypesSupported:                 []string{},
+		GrantTypesSupported:                    grants,
+		TokenEndpointAuthMethodsSupported:      methods,
+		RevocationEndpointAuthMethodsSupported: methods,
+		IntrospectionEndpointAuthMethodsSupported: methods,
+	}
+
+	meta.TLSClientCertificateBoundAccessTokens = h.opts.MTLS
+
+	if h.opts.Patrons != nil {
+		meta.AuthorizationEndpoint = base + "/auth/authorize"
+		meta.ResponseTypesSupported = []string{"code"}
+		meta.GrantTypesSupported = append(meta.GrantTypesSupported, GrantAuthorizationCode)
+		meta.CodeChallengeMethodsSupported = []string{"S256"}
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, meta)
+}
@@ -0,0 +1,300 @@
+// Package oidc verifies access tokens issued by external OpenID Connect
+// providers, such as a company SSO, and maps their claims to local scopes.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey  = errors.New("token signed with an unknown key")
+	ErrIssuerMatch = errors.New("discovery document issuer doesn't match the configured issuer")
+)
+
+// Config describes one trusted external issuer.
+type Config struct {
+	Issuer   string // must match the iss claim and the discovery document exactly
+	Audience string // required aud of the tokens, usually the id of this API at the provider
+
+	// ScopeClaim and RolesClaim name the claims whose values are looked up in
+	// Mappings, which lists the local scopes each value grants. Values that
+	// aren't mapped grant nothing. Nested claims are written with dots, as in
+	// "realm_access.roles".
+	ScopeClaim string
+	RolesClaim string
+	Mappings   map[string][]string
+
+	// JWKSRefresh is how long fetched keys are trusted before being fetched again.
+	JWKSRefresh time.Duration
+}
+
+// minRefetch limits the JWKS fetches caused by tokens with an unknown kid, so
+// forged tokens can't make us hammer the provider.
+const minRefetch = 30 * time.Second
+
+type key struct {
+	public any
+	algs   []string
+}
+
+// Provider verifies the tokens of one issuer, with its keys fetched from the
+// jwks_uri of the discovery document and cached.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	fetch sync.Mutex // one discovery or JWKS request at a time
+
+	mu        sync.RWMutex
+	jwksURI   string
+	keys      map[string]key // kid -> key
+	fetchedAt time.Time
+}
+
+// NewProvider creates a Provider. Nothing is fetched until the first token
+// arrives, so an unreachable provider doesn't prevent the server from starting.
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	if cfg.JWKSRefresh <= 0 {
+		cfg.JWKSRefresh = time.Hour
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &Provider{cfg: cfg, client: client}
+}
+
+// Issuer returns the iss claim this provider verifies.
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// Verify checks the signature and registered claims of token and maps it to
+// local claims: sub is kept, cid comes from azp or client_id and scope from Mappings.
+func (p *Provider) Verify(ctx context.Context, token string) (*auth.Claims, error) {
+	mc := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, mc,
+		func(t *jwt.Token) (any, error) { return p.verifyingKey(ctx, t) },
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second)) // clocks of different hosts drift
+	if err != nil {
+		return nil, err
+	}
+
+	c := &auth.Claims{Scope: strings.Join(p.mapScopes(mc), " ")}
+	c.Issuer = p.cfg.Issuer
+	c.Subject, _ = mc["sub"].(string)
+	c.ID, _ = mc["jti"].(string)
+
+	c.ClientID, _ = mc["azp"].(string)
+	if c.ClientID == "" {
+		c.ClientID, _ = mc["client_id"].(string)
+	}
+
+	return c, nil
+}
+
+// mapScopes collects the local scopes granted by the scope and roles claims.
+func (p *Provider) mapScopes(mc jwt.MapClaims) []string {
+	var values []string
+	for _, name := range []string{p.cfg.ScopeClaim, p.cfg.RolesClaim} {
+		if name != "" {
+			values = append(values, claimValues(lookupClaim(mc, name))...)
+		}
+	}
+
+	var out []string
+	for _, v := range values {
+		for _, s := range p.cfg.Mappings[v] {
+			if !slices.Contains(out, s) {
+				out = append(out, s)
+			}
+		}
+	}
+
+	return out
+}
+
+// lookupClaim follows a dotted path through nested claim objects.
+func lookupClaim(mc jwt.MapClaims, path string) any {
+	var cur any = map[string]any(mc)
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = obj[part]
+	}
+
+	return cur
+}
+
+// claimValues reads a claim holding either a space-delimited string or an array of strings.
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// verifyingKey finds the key named by the kid header, refetching the JWKS when
+// the kid is unknown (the provider probably rotated its keys) or the cache expired.
+func (p *Provider) verifyingKey(ctx context.Context, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	k, found, stale := p.lookup(kid)
+	if !found || stale {
+		if err := p.refresh(ctx, stale); err != nil {
+			if !found {
+				return nil, fmt.Errorf("refresh: %w", err)
+			}
+			// keep using a stale key rather than failing while the provider is unreachable
+		}
+		k, found, _ = p.lookup(kid)
+	}
+
+	if !found {
+		return nil, ErrUnknownKey
+	}
+
+	if !slices.Contains(k.algs, t.Method.Alg()) {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	return k.public, nil
+}
+
+func (p *Provider) lookup(kid string) (key, bool, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	k, ok := p.keys[kid]
+	return k, ok, time.Since(p.fetchedAt) > p.cfg.JWKSRefresh
+}
+
+// refresh fetches the discovery document (once) and the JWKS. Fetches for an
+// unknown kid are skipped if the keys were fetched less than minRefetch ago.
+func (p *Provider) refresh(ctx context.Context, expired bool) error {
+	p.fetch.Lock()
+	defer p.fetch.Unlock()
+
+	p.mu.RLock()
+	jwksURI, fetchedAt := p.jwksURI, p.fetchedAt
+	p.mu.RUnlock()
+
+	// another request may have refreshed the keys while this one waited for the lock
+	age := time.Since(fetchedAt)
+	if age < minRefetch || (expired && age < p.cfg.JWKSRefresh) {
+		return nil
+	}
+
+	if jwksURI == "" {
+		uri, err := p.discover(ctx)
+		if err != nil {
+			return fmt.Errorf("discover: %w", err)
+		}
+		jwksURI = uri
+	}
+
+	var set jwkSet
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return fmt.Errorf("getJSON %s: %w", jwksURI, err)
+	}
+
+	keys := make(map[string]key, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		public, algs, err := k.publicKey()
+		if err != nil {
+			continue // a key we can't use doesn't invalidate the others
+		}
+
+		if k.Alg != "" {
+			if !slices.Contains(algs, k.Alg) {
+				continue
+			}
+			algs = []string{k.Alg}
+		}
+
+		keys[k.Kid] = key{public: public, algs: algs}
+	}
+
+	p.mu.Lock()
+	p.jwksURI, p.keys, p.fetchedAt = jwksURI, keys, time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// discover reads the jwks_uri from the OpenID Connect discovery document.
+func (p *Provider) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	url := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, url, &doc); err != nil {
+		return "", fmt.Errorf("getJSON %s: %w", url, err)
+	}
+
+	if doc.Issuer != p.cfg.Issuer {
+		return "", ErrIssuerMatch
+	}
+
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document has no jwks_uri")
+	}
+
+	return doc.JWKSURI, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return json.NewDecoder(http.MaxBytesReader(nil, res.Body, 1<<20)).Decode(out)
+}
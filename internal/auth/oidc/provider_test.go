@@ -0,0 +1,356 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const audience = "library-api"
+
+// fakeIdP is an in-process identity provider serving a discovery document and
+// a JWKS, and signing tokens with its keys.
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu              sync.Mutex
+	discoveryIssuer string // issuer announced by the discovery document
+	published       []jwk
+	private         map[string]crypto.Signer // kid -> key
+
+	jwksHits atomic.Int32
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{t: t, private: map[string]crypto.Signer{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   idp.discoveryIssuer,
+			"jwks_uri": idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksHits.Add(1)
+
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		json.NewEncoder(w).Encode(jwkSet{Keys: idp.published})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	idp.discoveryIssuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *fakeIdP) issuer() string {
+	return idp.server.URL
+}
+
+// addRSAKey publishes a new RSA key for alg (empty to leave it out of the JWK).
+func (idp *fakeIdP) addRSAKey(kid, alg string) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding
+	idp.add(kid, k, jwk{Kty: "RSA", Kid: kid, Use: "sig", Alg: alg,
+		N: b64.EncodeToString(k.N.Bytes()),
+		E: b64.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+	})
+}
+
+func (idp *fakeIdP) addECKey(kid string) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding
+	idp.add(kid, k, jwk{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256",
+		X: b64.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+		Y: b64.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+	})
+}
+
+func (idp *fakeIdP) add(kid string, k crypto.Signer, public jwk) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.private[kid] = k
+	idp.published = append(idp.published, public)
+}
+
+// sign signs claims with the key kid, which doesn't need to be published.
+func (idp *fakeIdP) sign(method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	k, ok := idp.private[kid]
+	idp.mu.Unlock()
+
+	if !ok {
+		var err error
+		if k, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			idp.t.Fatal(err)
+		}
+	}
+
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+
+	s, err := tok.SignedString(k)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	return s
+}
+
+// claims are valid claims for the provider, with extra merged in.
+func (idp *fakeIdP) claims(extra jwt.MapClaims) jwt.MapClaims {
+	mc := jwt.MapClaims{
+		"iss": idp.issuer(),
+		"aud": audience,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		mc[k] = v
+	}
+
+	return mc
+}
+
+func (idp *fakeIdP) provider(cfg Config) *Provider {
+	cfg.Issuer = idp.issuer()
+	cfg.Audience = audience
+	return NewProvider(cfg, idp.server.Client())
+}
+
+func TestVerifyMapsScopesAndRoles(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addRSAKey("k1", "RS256")
+
+	p := idp.provider(Config{
+		RolesClaim: "realm_access.roles",
+		Mappings: map[string][]string{
+			"library.read": {"books:read"},
+			"librarian":    {"circulation", "books:read"},
+		},
+	})
+
+	token := idp.sign(jwt.SigningMethodRS256, "k1", idp.claims(jwt.MapClaims{
+		"scope":        "openid library.read",
+		"realm_access": map[string]any{"roles": []string{"librarian", "guest"}},
+		"azp":          "web-app",
+		"jti":          "t-1",
+	}))
+
+	c, err := p.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if c.Scope != "books:read circulation" {
+		t.Errorf("Scope = %q, want %q", c.Scope, "books:read circulation")
+	}
+	if c.Subject != "alice" || c.ClientID != "web-app" || c.ID != "t-1" || c.Issuer != idp.issuer() {
+		t.Errorf("claims = %+v", c)
+	}
+}
+
+func TestVerifyWithoutMappedValuesGrantsNothing(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addRSAKey("k1", "RS256")
+
+	p := idp.provider(Config{Mappings: map[string][]string{"library.read": {"books:read"}}})
+
+	token := idp.sign(jwt.SigningMethodRS256, "k1", idp.claims(jwt.MapClaims{"scope": "openid email", "client_id": "cli"}))
+
+	c, err := p.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if c.Scope != "" || c.ClientID != "cli" {
+		t.Errorf("claims = %+v, want no scope and client cli", c)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.addRSAKey("k1", "RS256")
+	idp.discoveryIssuer = idp.issuer() + "/evil"
+
+	p := idp.provider(Config{})
+
+	_, err := p.Verify(context.Background(), idp.sign(jwt.SigningMethodRS256, "k1", idp.claims(nil)))
+	if !errors.Is(err, ErrIssuerMatch) {
+		t.Fatalf("Verify = %v, want ErrIssuerMatch", err)
+	}
+
+	if hits := idp.jwksHits.Load(); hits != 0 {
+		t.Errorf("JWKS fetched %d times after a mismatching discovery document", hits)
+	}
+}
+
+func TestUnknownKidRefetchIsRateLimited(t *testing.T) {
+	ctx := context.Background()
+	idp := newFakeIdP(t)
+	idp.addRSAKey("k1", "RS256")
+
+	p := idp.provider(Config{})
+
+	if _, err := p.Verify(ctx, idp.sign(jwt.SigningMethodRS256, "k1", idp.claims(nil))); err != nil {
+		t.Fatalf("Verify k1: %v", err)
+	}
+	if hits := idp.jwksHits.Load(); hits != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", hits)
+	}
+
+	// the provider rotates right after the keys were fetched
+	idp.addECKey("k2")
+	rotated := idp.sign(jwt.SigningMethodES256, "k2", idp.claims(nil))
+
+	if _, err := p.Verify(ctx, rotated); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify k2 within minRefetch = %v, want ErrUnknownKey", err)
+	}
+	if hits := idp.jwksHits.Load(); hits != 1 {
+		t.Fatalf("JWKS fetched %d times within minRefetch, want 1", hits)
+	}
+
+	// once minRefetch passed, the unknown kid triggers a fetch
+	p.mu.Lock()
+	p.fetchedAt = p.fetchedAt.Add(-minRefetch)
+	p.mu.Unlock()
+
+	if _, err := p.Verify(ctx, rotated); err != nil {
+		t.Fatalf("Verify k2 after minRefetch: %v", err)
+	}
+	if hits := idp.jwksHits.Load(); hits != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", hits)
+	}
+
+	// a flood of forged kids doesn't reach the provider
+	for range 5 {
+		forged := idp.sign(jwt.SigningMethodRS256, "forged", idp.claims(nil))
+		if _, err := p.Verify(ctx, forged); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Verify forged = %v, want ErrUnknownKey", err)
+		}
+	}
+	if hits := idp.jwksHits.Load(); hits != 2 {
+		t.Errorf("JWKS fetched %d times for forged kids, want 2", hits)
+	}
+}
+
+func TestVerifyRejectsAlgorithmKeyMismatch(t *testing.T) {
+	ctx := context.Background()
+	idp := newFakeIdP(t)
+	idp.addRSAKey("rsa", "RS256")
+	idp.addECKey("ec")
+
+	p := idp.provider(Config{})
+
+	if _, err := p.Verify(ctx, idp.sign(jwt.SigningMethodRS256, "rsa", idp.claims(nil))); err != nil {
+		t.Fatalf("Verify RS256: %v", err)
+	}
+
+	tests := map[string]string{
+		// the JWK pins the RSA key to RS256
+		"PS256 with an RS256 key": idp.sign(jwt.SigningMethodPS256, "rsa", idp.claims(nil)),
+		// ES256 header naming the RSA key, signed by the EC key
+		"ES256 naming an RSA key": func() string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodES256, idp.claims(nil))
+			tok.Header["kid"] = "rsa"
+			s, err := tok.SignedString(idp.private["ec"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}(),
+		// an HMAC keyed with public material must never be accepted
+		"HS256": func() string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims(nil))
+			tok.Header["kid"] = "ec"
+			s, err := tok.SignedString([]byte("secret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}(),
+		"none": func() string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodNone, idp.claims(nil))
+			tok.Header["kid"] = "rsa"
+			s, err := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}(),
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Verify(ctx, token); err == nil {
+				t.Fatal("Verify accepted the token")
+			}
+		})
+	}
+}
+
+func TestVerifyRegisteredClaims(t *testing.T) {
+	ctx := context.Background()
+	idp := newFakeIdP(t)
+	idp.addRSAKey("k1", "RS256")
+
+	p := idp.provider(Config{})
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"other audience", idp.claims(jwt.MapClaims{"aud": "another-api"}), jwt.ErrTokenInvalidAudience},
+		{"no audience", func() jwt.MapClaims { mc := idp.claims(nil); delete(mc, "aud"); return mc }(), jwt.ErrTokenRequiredClaimMissing},
+		{"other issuer", idp.claims(jwt.MapClaims{"iss": "https://elsewhere"}), jwt.ErrTokenInvalidIssuer},
+		{"expired", idp.claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), jwt.ErrTokenExpired},
+		{"no expiry", func() jwt.MapClaims { mc := idp.claims(nil); delete(mc, "exp"); return mc }(), jwt.ErrTokenRequiredClaimMissing},
+		{"within leeway", idp.claims(jwt.MapClaims{"exp": time.Now().Add(-10 * time.Second).Unix()}), nil},
+		{"audience in a list", idp.claims(jwt.MapClaims{"aud": []string{"other", audience}}), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Verify(ctx, idp.sign(jwt.SigningMethodRS256, "k1", tt.claims))
+			if tt.want == nil && err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadPEMKey reads a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1) and
+// picks the algorithm from the key type.
+func LoadPEMKey(path string) (*SigningKey, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	var private any
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	alg, err := AlgorithmFor(private)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	k, err := NewSigningKey(alg, private)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		k.CreatedAt = info.ModTime().UTC()
+	}
+
+	return k, nil
+}
+
+// WritePEMKey stores the private key of k as PKCS#8 in dir, named after its kid.
+// The file is only readable by the owner.
+func WritePEMKey(dir string, k *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, k.ID+".pem.tmp")
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, k.ID+".pem"))
+}
+
+// RemovePEMKey deletes the file written by WritePEMKey for kid, if any.
+func RemovePEMKey(dir, kid string) error {
+	err := os.Remove(filepath.Join(dir, kid+".pem"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// LoadPEMDir loads every *.pem key in dir, oldest first.
+func LoadPEMDir(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, p := range paths {
+		k, err := LoadPEMKey(p)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	slices.SortFunc(keys, func(a, b *SigningKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return keys, nil
+}
+
+// KeyRotator activates a freshly generated key every interval. When dir is set
+// it is shared by the instances: new keys are written there and retired ones
+// removed, each instance reloads it every syncEvery and on an unknown kid, and
+// only the first instance to claim an interval generates its key, so they all
+// converge on the same key set.
+type KeyRotator struct {
+	keys      *KeySet
+	alg       string
+	dir       string
+	interval  time.Duration
+	overlap   time.Duration
+	syncEvery time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu     sync.Mutex
+	epoch  time.Time // start of the last interval handled
+	synced time.Time // last reload, which unknown kids can't repeat more than once per minSync
+}
+
+// minSync rate limits the reloads of the key directory caused by unknown kids.
+const minSync = time.Second
+
+func NewKeyRotator(keys *KeySet, alg, dir string, interval, overlap time.Duration) *KeyRotator {
+	r := &KeyRotator{
+		keys:      keys,
+		alg:       alg,
+		dir:       dir,
+		interval:  interval,
+		overlap:   overlap,
+		syncEvery: min(interval, time.Minute),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		epoch:     time.Now().Truncate(interval),
+	}
+
+	if dir != "" {
+		keys.mu.Lock()
+		keys.reload = r.syncUnknown
+		keys.mu.Unlock()
+	}
+
+	return r
+}
+
+// Start runs the rotation loop in the background until Stop is called.
+func (r *KeyRotator) Start(logf func(format string, args ...any)) {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.syncEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case now := <-ticker.C:
+				if err := r.tick(now); err != nil {
+					logf("KeyRotator.tick: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// tick reloads the directory and, on the first tick of an interval, rotates if
+// no other instance has claimed the interval yet. Intervals are aligned on the
+// clock so every instance agrees on them.
+func (r *KeyRotator) tick(now time.Time) error {
+	if err := r.Sync(); err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	epoch := now.Truncate(r.interval)
+
+	r.mu.Lock()
+	due := epoch.After(r.epoch)
+	r.epoch = epoch
+	r.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	claimed, err := r.claim(epoch)
+	if err != nil {
+		return fmt.Errorf("claim: %w", err)
+	}
+
+	if !claimed {
+		return nil
+	}
+
+	return r.Rotate()
+}
+
+// claim creates the marker of the interval starting at epoch in dir, which
+// fails for every instance but the first. Markers of older intervals are removed.
+func (r *KeyRotator) claim(epoch time.Time) (bool, error) {
+	if r.dir == "" {
+		return true, nil
+	}
+
+	name := fmt.Sprintf(".rotate-%d", epoch.Unix())
+	f, err := os.OpenFile(filepath.Join(r.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+
+	old, err := filepath.Glob(filepath.Join(r.dir, ".rotate-*"))
+	if err != nil {
+		return true, err
+	}
+	for _, p := range old {
+		if filepath.Base(p) != name {
+			os.Remove(p)
+		}
+	}
+
+	return true, nil
+}
+
+// Sync loads the keys other instances wrote to the directory into the key set
+// and removes the files of the keys it drops.
+func (r *KeyRotator) Sync() error {
+	if r.dir == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sync()
+}
+
+// sync is Sync with the lock held.
+func (r *KeyRotator) sync() error {
+	r.synced = time.Now()
+
+	loaded, err := LoadPEMDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("LoadPEMDir: %w", err)
+	}
+
+	return r.remove(r.keys.Load(loaded, r.overlap))
+}
+
+// syncUnknown is the reload of the key set: it syncs unless the last sync was
+// less than minSync ago, so made up kids can't keep the directory busy.
+func (r *KeyRotator) syncUnknown() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.synced) < minSync {
+		return false
+	}
+
+	return r.sync() == nil
+}
+
+// Rotate generates and activates a new key right away.
+func (r *KeyRotator) Rotate() error {
+	k, err := GenerateSigningKey(r.alg)
+	if err != nil {
+		return fmt.Errorf("GenerateSigningKey: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dir != "" {
+		if err := WritePEMKey(r.dir, k); err != nil {
+			return fmt.Errorf("WritePEMKey: %w", err)
+		}
+	}
+
+	return r.remove(r.keys.Rotate(k, r.overlap))
+}
+
+// remove deletes the files of retired keys. It must be called with the lock held.
+func (r *KeyRotator) remove(kids []string) error {
+	if r.dir == "" {
+		return nil
+	}
+
+	for _, kid := range kids {
+		if err := RemovePEMKey(r.dir, kid); err != nil {
+			return fmt.Errorf("RemovePEMKey: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Stop ends the rotation loop and waits for it to exit.
+func (r *KeyRotator) Stop() {
+	close(r.stop)
+	<-r.done
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// instance is one server of a deployment sharing a key directory.
+type instance struct {
+	keys    *KeySet
+	rotator *KeyRotator
+	service *JwtService
+}
+
+func newInstance(t *testing.T, dir string) instance {
+	t.Helper()
+
+	loaded, err := LoadPEMDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := NewKeySet()
+	keys.Load(loaded, 2*time.Hour)
+
+	return instance{
+		keys:    keys,
+		rotator: NewKeyRotator(keys, AlgES256, dir, time.Hour, 2*time.Hour),
+		service: &JwtService{keys: keys, issuer: "booksrv", audience: "books", opts: TokenOptions{AccessTTL: time.Minute}},
+	}
+}
+
+func activeID(t *testing.T, keys *KeySet) string {
+	t.Helper()
+
+	k, err := keys.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k.ID
+}
+
+// verifiesOn issues a token on from and checks on accepts it.
+func verifiesOn(t *testing.T, from, on instance) error {
+	t.Helper()
+
+	res, err := from.service.IssueToken(context.Background(), "c1", []string{"books:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = on.service.parse(res.AccessToken)
+	return err
+}
+
+func TestKeyRotatorSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePEMKey(dir, first); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := newInstance(t, dir), newInstance(t, dir)
+	if activeID(t, a.keys) != first.ID || activeID(t, b.keys) != first.ID {
+		t.Fatalf("both instances should start with %s", first.ID)
+	}
+
+	// both tick in the next interval: only the first one generates a key
+	next := time.Now().Truncate(time.Hour).Add(time.Hour)
+	if err := a.rotator.tick(next); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.rotator.tick(next.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := activeID(t, a.keys)
+	if rotated == first.ID {
+		t.Fatal("a didn't rotate on the first tick of the interval")
+	}
+	if got := activeID(t, b.keys); got != rotated {
+		t.Fatalf("b signs with %s, a with %s", got, rotated)
+	}
+
+	onDisk, err := LoadPEMDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onDisk) != 2 {
+		t.Errorf("%d keys in the directory, want the first and one rotated key", len(onDisk))
+	}
+
+	if ja, jb := a.keys.JWKS(), b.keys.JWKS(); !reflect.DeepEqual(ja, jb) || len(ja.Keys) != 2 {
+		t.Errorf("JWKS differ:\na: %+v\nb: %+v", ja, jb)
+	}
+
+	for name, pair := range map[string][2]instance{"a to b": {a, b}, "b to a": {b, a}} {
+		if err := verifiesOn(t, pair[0], pair[1]); err != nil {
+			t.Errorf("token from %s: %v", name, err)
+		}
+	}
+
+	// a later tick in the same interval does nothing
+	if err := b.rotator.tick(next.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeID(t, b.keys); got != rotated {
+		t.Errorf("b rotated again within the interval, to %s", got)
+	}
+}
+
+func TestKeySetReloadsOnUnknownKid(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := GenerateSigningKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePEMKey(dir, first); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := newInstance(t, dir), newInstance(t, dir)
+
+	// a rotates out of schedule, b hasn't synced since
+	if err := a.rotator.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown kids reload at most once per minSync
+	b.rotator.synced = time.Now()
+	if err := verifiesOn(t, a, b); !errors.Is(err, jwt.ErrTokenUnverifiable) {
+		t.Fatalf("token within minSync of the last reload = %v, want %v", err, jwt.ErrTokenUnverifiable)
+	}
+
+	b.rotator.synced = time.Now().Add(-minSync)
+	if err := verifiesOn(t, a, b); err != nil {
+		t.Fatalf("token with a kid written by another instance: %v", err)
+	}
+
+	// the reload activated a's key on b too
+	if got, want := activeID(t, b.keys), activeID(t, a.keys); got != want {
+		t.Errorf("b signs with %s after reloading, want %s", got, want)
+	}
+	if _, ok := b.keys.Lookup(first.ID); !ok {
+		t.Error("b dropped the previous key before its overlap")
+	}
+}
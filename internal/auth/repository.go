@@ -1,29 +1,57 @@
 package auth
 
-// ClientRepository queries the InMemoryStore to validate the existence of a client.
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"sync"
+)
+
+// ClientRepository finds the registered OAuth clients. Implementations live in
+// the clients package (memory, JSON file and MySQL).
 type ClientRepository interface {
-	Validate(clientID, clientSecret string) bool
+	FindById(ctx context.Context, clientID string) (Client, error)
 }
 
-// InMemoryClientRepo stores credentials (id and secret) in memory.
-type InMemoryClientRepo struct {
-	credentials map[string]string // id & secret
+// ClientStore is a ClientRepository that can also be changed, used by the admin API.
+type ClientStore interface {
+	ClientRepository
+	List(ctx context.Context) ([]Client, error)
+	Create(ctx context.Context, c Client) error
+	Update(ctx context.Context, c Client) error
+	Delete(ctx context.Context, clientID string) error
 }
 
-func NewInMemoryClientRepo(seed map[string]string) *InMemoryClientRepo {
-	return &InMemoryClientRepo{credentials: seed}
-}
+// dummyHash is verified against when the client doesn't exist, so unknown and
+// known client ids take the same time to be rejected.
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := secret.Hash("dummy-secret")
+	return h
+})
+
+// ValidateClient returns the client identified by clientID if clientSecret matches
+// its stored hash and the client is enabled. Every failure is reported as
+// ErrInvalidCredentials so callers can't enumerate client ids.
+func ValidateClient(ctx context.Context, repository ClientRepository, clientID, clientSecret string) (Client, error) {
+	client, err := repository.FindById(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		secret.Verify(dummyHash(), clientSecret)
+		return Client{}, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return Client{}, fmt.Errorf("repository.FindById: %w", err)
+	}
 
-// Validate returns if requesting client (clientID and clientSecret) exist in the database.
-func (r *InMemoryClientRepo) Validate(clientID, clientSecret string) bool {
-	secret, exists := r.credentials[clientID]
-	if !exists {
-		return false
+	ok, err := secret.Verify(client.SecretHash, clientSecret)
+	if err != nil {
+		return Client{}, fmt.Errorf("secret.Verify: %w", err)
 	}
 
-	if secret != clientSecret {
-		return false
+	if !ok || client.Disabled {
+		return Client{}, ErrInvalidCredentials
 	}
 
-	return true
+	return client, nil
 }
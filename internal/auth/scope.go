@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes a client can be granted.
+const (
+	ScopeBooksRead   = "books:read"
+	ScopeBooksWrite  = "books:write"
+	ScopeCirculation = "circulation"
+	ScopeAdmin       = "admin"
+
+	// OpenID Connect scopes: openid gets an ID token along with the access token,
+	// profile adds the patron's name and email to it.
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+)
+
+// KnownScopes lists every scope understood by the API.
+var KnownScopes = []string{ScopeBooksRead, ScopeBooksWrite, ScopeCirculation, ScopeAdmin, ScopeOpenID, ScopeProfile}
+
+// PatronScopes are the scopes a token issued to a patron may hold, whatever the client is allowed.
+var PatronScopes = []string{ScopeBooksRead, ScopeCirculation, ScopeOpenID, ScopeProfile}
+
+// ParseScope splits a space-delimited scope string (RFC 6749, section 3.3), dropping duplicates.
+func ParseScope(scope string) []string {
+	var out []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// ValidateScopes returns ErrInvalidScope if any scope is unknown.
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if !slices.Contains(KnownScopes, s) {
+			return ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+// grantScopes returns the scopes to embed in a token. An empty request grants
+// every scope of the client; otherwise each requested scope must be allowed.
+func grantScopes(client Client, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return client.Scopes, nil
+	}
+
+	for _, s := range requested {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	return requested, nil
+}
+
+// grantPatronScopes is grantScopes for tokens issued to a patron through the
+// authorization code flow, further limited to PatronScopes.
+func grantPatronScopes(client Client, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		var out []string
+		for _, s := range client.Scopes {
+			if slices.Contains(PatronScopes, s) {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+
+	for _, s := range requested {
+		if !slices.Contains(PatronScopes, s) || !slices.Contains(client.Scopes, s) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	return requested, nil
+}
+
+// RequireScope only lets through tokens holding every one of the given scopes.
+// It must run after RequireAuth.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	required := strings.Join(scopes, " ")
+
+	return func(ctx *gin.Context) {
+		granted := ctx.GetStringSlice("scopes")
+
+		for _, s := range scopes {
+			if !slices.Contains(granted, s) {
+				ctx.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+required+`"`)
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrInsufficientScope.Error()})
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
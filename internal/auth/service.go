@@ -1,42 +1,240 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Auth Service offers the necessary methods for Token creation and validation.
 type Service interface {
-	IssueToken(clientID string, ttl time.Duration) (TokenRes, error)
-	ParseAndValidate(tokenStr string) (*Claims, error)
+	IssueToken(ctx context.Context, clientID string, scopes []string) (TokenRes, error)
+	Refresh(ctx context.Context, client Client, refreshToken string, scopes []string) (TokenRes, error)
+	Revoke(ctx context.Context, clientID, token string) error
+	ParseAndValidate(ctx context.Context, tokenStr string) (*Claims, error)
+	Introspect(ctx context.Context, clientID, token string) (IntrospectionRes, error)
+	CreateCode(ctx context.Context, code AuthCode) (string, error)
+	ExchangeCode(ctx context.Context, clientID, code, redirectURI, verifier string) (TokenRes, error)
+}
+
+// TokenOptions configures the lifetime and bookkeeping of the issued tokens.
+type TokenOptions struct {
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration // zero disables refresh tokens
+	Revocations RevocationList
+	Refresh     RefreshStore
+	Codes       CodeStore
 }
 
 // JwtService is the implementation of AuthService by using Jwt.
 type JwtService struct {
-	secretKey []byte
-	issuer    string
-	audience  string
+	keys     *KeySet
+	issuer   string
+	audience string
+	opts     TokenOptions
 }
 
-func NewService(secretKey, issuer, audience string) Service {
+// NewService creates a JwtService signing with the active key of keys.
+func NewService(keys *KeySet, issuer, audience string, opts TokenOptions) Service {
 	j := JwtService{
-		secretKey: []byte(secretKey),
-		issuer:    issuer,   // std field in jwt; who created the token.
-		audience:  audience, // std field in jwt;  who is supposed to accept and trust this token.
+		keys:     keys,
+		issuer:   issuer,   // std field in jwt; who created the token.
+		audience: audience, // std field in jwt;  who is supposed to accept and trust this token.
+		opts:     opts,
 	}
 
 	return &j
 }
 
-// Issues a new token based on client credentials and desired ttl. Returns a TokenRes or an error.
-func (s *JwtService) IssueToken(clientID string, ttl time.Duration) (TokenRes, error) {
+// IssueToken issues an access token, and a refresh token starting a new family
+// when refresh tokens are enabled.
+func (s *JwtService) IssueToken(ctx context.Context, clientID string, scopes []string) (TokenRes, error) {
+	return s.issue(ctx, clientID, "", scopes, uuid.NewString())
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token (rotation).
+// Presenting a refresh token that was already exchanged revokes its whole family,
+// since either the legitimate client or an attacker holds a stolen copy.
+// client is the authenticated client, whose current scopes bound the new tokens.
+func (s *JwtService) Refresh(ctx context.Context, client Client, refreshToken string, scopes []string) (TokenRes, error) {
+	if s.opts.RefreshTTL <= 0 {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	hash := HashToken(refreshToken)
+
+	// only the client the token was issued to may use it up, or trip the reuse
+	// detection and revoke the family
+	found, err := s.opts.Refresh.FindRefresh(ctx, hash)
+	if errors.Is(err, ErrRefreshNotFound) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return TokenRes{}, fmt.Errorf("Refresh.FindRefresh: %w", err)
+	}
+
+	if found.ClientID != client.ID || time.Now().After(found.ExpiresAt) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	current, err := s.opts.Refresh.MarkRefreshUsed(ctx, hash)
+	if errors.Is(err, ErrRefreshReused) {
+		if err := s.revokeFamily(ctx, current.FamilyID); err != nil {
+			return TokenRes{}, fmt.Errorf("revokeFamily: %w", err)
+		}
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	if errors.Is(err, ErrRefreshNotFound) {
+		return TokenRes{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return TokenRes{}, fmt.Errorf("Refresh.MarkRefreshUsed: %w", err)
+	}
+
+	// the new tokens may narrow the scopes down, never widen them, and lose
+	// the scopes taken from the client since the family started
+	var allowed []string
+	for _, scope := range current.Scopes {
+		if slices.Contains(client.Scopes, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	granted, err := grantScopes(Client{Scopes: allowed}, scopes)
+	if err != nil {
+		return TokenRes{}, err
+	}
+
+	return s.issue(ctx, client.ID, current.Subject, granted, current.FamilyID)
+}
+
+// Revoke implements RFC 7009: token may be a refresh token (its family is revoked)
+// or an access token (its jti is revoked). Tokens that are unknown, invalid or
+// belong to another client are ignored, as the RFC requires.
+func (s *JwtService) Revoke(ctx context.Context, clientID, token string) error {
+	if s.opts.RefreshTTL > 0 {
+		rt, err := s.opts.Refresh.FindRefresh(ctx, HashToken(token))
+		if err == nil {
+			if rt.ClientID != clientID {
+				return nil
+			}
+			return s.revokeFamily(ctx, rt.FamilyID)
+		}
+
+		if !errors.Is(err, ErrRefreshNotFound) {
+			return fmt.Errorf("Refresh.FindRefresh: %w", err)
+		}
+	}
+
+	claims, err := s.parse(token)
+	if err != nil || claims.ClientID != clientID {
+		return nil
+	}
+
+	return s.opts.Revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// Introspect reports whether token is currently valid, following RFC 7662.
+// Access tokens are checked like ParseAndValidate does, so revocation shows up
+// right away. Refresh tokens are only described to the client they belong to.
+func (s *JwtService) Introspect(ctx context.Context, clientID, token string) (IntrospectionRes, error) {
+	if s.opts.RefreshTTL > 0 {
+		rt, err := s.opts.Refresh.FindRefresh(ctx, HashToken(token))
+		if err == nil {
+			if rt.ClientID != clientID || rt.Used || time.Now().After(rt.ExpiresAt) {
+				return IntrospectionRes{}, nil
+			}
+
+			return IntrospectionRes{
+				Active:    true,
+				ClientID:  rt.ClientID,
+				Sub:       rt.Subject,
+				Scope:     strings.Join(rt.Scopes, " "),
+				TokenType: "refresh_token",
+				Exp:       rt.ExpiresAt.Unix(),
+				Iss:       s.issuer,
+			}, nil
+		}
+
+		if !errors.Is(err, ErrRefreshNotFound) {
+			return IntrospectionRes{}, fmt.Errorf("Refresh.FindRefresh: %w", err)
+		}
+	}
+
+	claims, err := s.parse(token)
+	if err != nil {
+		return IntrospectionRes{}, nil
+	}
+
+	revoked, err := s.opts.Revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		// the token can't be vouched for, the caller must not treat it as inactive either
+		return IntrospectionRes{}, fmt.Errorf("Revocations.IsRevoked: %w", err)
+	}
+
+	if revoked {
+		return IntrospectionRes{}, nil
+	}
+
+	res := IntrospectionRes{
+		Active:    true,
+		ClientID:  claims.ClientID,
+		Sub:       claims.Subject,
+		Scope:     claims.Scope,
+		TokenType: "access_token",
+		Iss:       claims.Issuer,
+		Aud:       claims.Audience,
+		Jti:       claims.ID,
+		Cnf:       claims.Cnf,
+	}
+	if claims.ExpiresAt != nil {
+		res.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		res.Iat = claims.IssuedAt.Unix()
+	}
+
+	return res, nil
+}
+
+// revokeFamily drops every refresh token of a family and revokes the access tokens issued with them.
+func (s *JwtService) revokeFamily(ctx context.Context, familyID string) error {
+	revoked, err := s.opts.Refresh.RevokeFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+
+	for _, rt := range revoked {
+		if err := s.opts.Revocations.Revoke(ctx, rt.AccessJTI, rt.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issue signs an access token and, when enabled, stores a new refresh token of familyID.
+// subject is the patron the tokens are issued to, empty for client credentials.
+func (s *JwtService) issue(ctx context.Context, clientID, subject string, scopes []string, familyID string) (TokenRes, error) {
 	now := time.Now()
-	exp := now.Add(ttl)
+	exp := now.Add(s.opts.AccessTTL)
 	claims := &Claims{
 		ClientID: clientID,
+		Scope:    strings.Join(scopes, " "),
 		// still must write RegisteredClaims: jwt.RegisteredClaims{...} during initialization, because Go needs to know which anonymous field you’re populating.
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(), // jti, what the revocation list refers to
+			Subject:   subject,
 			Issuer:    s.issuer,
 			Audience:  []string{s.audience},
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -44,23 +242,86 @@ func (s *JwtService) IssueToken(clientID string, ttl time.Duration) (TokenRes, e
 		},
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := t.SignedString(s.secretKey)
+	if thumbprint := certThumbprintFrom(ctx); thumbprint != "" {
+		claims.Cnf = &Confirmation{X5tS256: thumbprint}
+	}
+
+	signed, err := s.sign(claims)
 	if err != nil {
 		return TokenRes{}, err
 	}
 
-	return TokenRes{AccessToken: signed, ExpiresIn: int64(ttl.Seconds())}, nil
+	res := TokenRes{AccessToken: signed, TokenType: "Bearer", ExpiresIn: int64(s.opts.AccessTTL.Seconds()), Scope: claims.Scope}
+	if s.opts.RefreshTTL <= 0 {
+		return res, nil
+	}
+
+	refresh, err := secret.Generate(32)
+	if err != nil {
+		return TokenRes{}, fmt.Errorf("secret.Generate: %w", err)
+	}
+
+	rt := RefreshToken{
+		Hash:            HashToken(refresh),
+		FamilyID:        familyID,
+		ClientID:        clientID,
+		Subject:         subject,
+		Scopes:          scopes,
+		ExpiresAt:       now.Add(s.opts.RefreshTTL),
+		AccessJTI:       claims.ID,
+		AccessExpiresAt: exp,
+	}
+	if err := s.opts.Refresh.SaveRefresh(ctx, rt); err != nil {
+		return TokenRes{}, fmt.Errorf("Refresh.SaveRefresh: %w", err)
+	}
+
+	res.RefreshToken = refresh
+	return res, nil
+}
+
+// sign signs claims with the active key.
+func (s *JwtService) sign(claims jwt.Claims) (string, error) {
+	key, err := s.keys.Active()
+	if err != nil {
+		return "", err
+	}
+
+	t := jwt.NewWithClaims(key.Method(), claims)
+	t.Header["kid"] = key.ID // tells verifiers which key of the JWKS to use
+
+	return t.SignedString(key.Private)
+}
+
+// ParseAndValidate parses a received token and returns its claims or an error,
+// rejecting tokens that were revoked.
+func (s *JwtService) ParseAndValidate(ctx context.Context, tokenStr string) (*Claims, error) {
+	c, err := s.parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ID != "" {
+		revoked, err := s.opts.Revocations.IsRevoked(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Revocations.IsRevoked: %w", err)
+		}
+
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return c, nil
 }
 
-// ParseAndValidate parses a received token and returns its claims or an error.
-func (s *JwtService) ParseAndValidate(tokenStr string) (*Claims, error) {
+// parse verifies the signature and the registered claims of a token.
+func (s *JwtService) parse(tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr,
-		&Claims{}, // Tells the library what type of claims you expect (my struct)
-		func(t *jwt.Token) (interface{}, error) { // This tells the library which secret key to use to verify the signature.
-			return s.secretKey, nil
-		}, jwt.WithAudience(s.audience), // ensures the token’s "aud" claim matches your expected audience.
-		jwt.WithIssuer(s.issuer)) //  ensures the token’s "iss" claim matches your expected issuer.
+		&Claims{},      // Tells the library what type of claims you expect (my struct)
+		s.verifyingKey, // This tells the library which key to use to verify the signature.
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}),
+		jwt.WithAudience(s.audience), // ensures the token’s "aud" claim matches your expected audience.
+		jwt.WithIssuer(s.issuer))     //  ensures the token’s "iss" claim matches your expected issuer.
 
 	if err != nil {
 		return nil, err
@@ -73,3 +334,36 @@ func (s *JwtService) ParseAndValidate(tokenStr string) (*Claims, error) {
 
 	return c, nil
 }
+
+// verifyingKey finds the key named by the token's kid header. Tokens without kid
+// (issued before key sets existed) are checked against the active key.
+func (s *JwtService) verifyingKey(t *jwt.Token) (any, error) {
+	var key *SigningKey
+
+	if kid, ok := t.Header["kid"].(string); ok {
+		k, found := s.keys.Lookup(kid)
+		if !found {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		key = k
+	} else {
+		k, err := s.keys.Active()
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	}
+
+	// a key only verifies the algorithm it was made for, which rules out alg confusion
+	if t.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	return key.Public(), nil
+}
+
+// Check confirms a signing key is loaded, used by the readiness probe.
+func (s *JwtService) Check(ctx context.Context) error {
+	_, err := s.keys.Active()
+	return err
+}
@@ -0,0 +1,177 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/auth/tokens"
+	"slices"
+	"testing"
+	"time"
+)
+
+var (
+	reader = auth.Client{ID: "reader", Scopes: []string{"books:read", "books:write"}}
+	other  = auth.Client{ID: "other", Scopes: []string{"books:read", "books:write"}}
+)
+
+func newService(t *testing.T, refreshTTL time.Duration) (auth.Service, *tokens.Memory) {
+	t.Helper()
+
+	k, err := auth.NewSigningKey(auth.AlgHS256, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := auth.NewKeySet()
+	keys.Add(k, true)
+
+	store := tokens.NewMemory()
+	return auth.NewService(keys, "booksrv", "books", auth.TokenOptions{
+		AccessTTL:   time.Minute,
+		RefreshTTL:  refreshTTL,
+		Revocations: store,
+		Refresh:     store,
+		Codes:       store,
+	}), store
+}
+
+func issue(t *testing.T, s auth.Service, c auth.Client) auth.TokenRes {
+	t.Helper()
+
+	res, err := s.IssueToken(context.Background(), c.ID, c.Scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestRefreshRotates(t *testing.T) {
+	s, _ := newService(t, time.Hour)
+	ctx := context.Background()
+	first := issue(t, s, reader)
+
+	second, err := s.Refresh(ctx, reader, first.RefreshToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q, want a new one", second.RefreshToken)
+	}
+
+	claims, err := s.ParseAndValidate(ctx, second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClientID != reader.ID || claims.Scope != "books:read books:write" {
+		t.Errorf("refreshed claims = %s %q", claims.ClientID, claims.Scope)
+	}
+
+	if _, err := s.Refresh(ctx, reader, second.RefreshToken, nil); err != nil {
+		t.Errorf("refreshing the rotated token: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	s, _ := newService(t, time.Hour)
+	ctx := context.Background()
+	first := issue(t, s, reader)
+
+	second, err := s.Refresh(ctx, reader, first.RefreshToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first token shows up again: someone holds a copy
+	if _, err := s.Refresh(ctx, reader, first.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidGrant) {
+		t.Fatalf("reused refresh token = %v, want %v", err, auth.ErrInvalidGrant)
+	}
+
+	if _, err := s.Refresh(ctx, reader, second.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidGrant) {
+		t.Errorf("refresh token of a revoked family = %v, want %v", err, auth.ErrInvalidGrant)
+	}
+	if _, err := s.ParseAndValidate(ctx, second.AccessToken); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("access token of a revoked family = %v, want %v", err, auth.ErrTokenRevoked)
+	}
+}
+
+func TestRefreshByAnotherClient(t *testing.T) {
+	s, store := newService(t, time.Hour)
+	ctx := context.Background()
+	victim := issue(t, s, reader)
+
+	if _, err := s.Refresh(ctx, other, victim.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidGrant) {
+		t.Fatalf("refresh with another client's token = %v, want %v", err, auth.ErrInvalidGrant)
+	}
+
+	rt, err := store.FindRefresh(ctx, auth.HashToken(victim.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Used {
+		t.Fatal("another client used up the token")
+	}
+
+	rotated, err := s.Refresh(ctx, reader, victim.RefreshToken, nil)
+	if err != nil {
+		t.Fatalf("the owner refreshing after another client tried: %v", err)
+	}
+
+	// replaying the used token from another client doesn't revoke the owner's family
+	if _, err := s.Refresh(ctx, other, victim.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidGrant) {
+		t.Fatalf("replay by another client = %v, want %v", err, auth.ErrInvalidGrant)
+	}
+	if _, err := s.Refresh(ctx, reader, rotated.RefreshToken, nil); err != nil {
+		t.Errorf("the owner's family after a replay by another client: %v", err)
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	s, store := newService(t, time.Nanosecond)
+	ctx := context.Background()
+	res := issue(t, s, reader)
+	time.Sleep(time.Millisecond)
+
+	if _, err := s.Refresh(ctx, reader, res.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidGrant) {
+		t.Fatalf("expired refresh token = %v, want %v", err, auth.ErrInvalidGrant)
+	}
+
+	rt, err := store.FindRefresh(ctx, auth.HashToken(res.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Used {
+		t.Error("an expired token was marked used")
+	}
+}
+
+func TestRefreshScopes(t *testing.T) {
+	s, _ := newService(t, time.Hour)
+	ctx := context.Background()
+	res := issue(t, s, reader)
+
+	narrowed, err := s.Refresh(ctx, reader, res.RefreshToken, []string{"books:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if narrowed.Scope != "books:read" {
+		t.Errorf("narrowed scope = %q, want books:read", narrowed.Scope)
+	}
+
+	// widening back is refused
+	if _, err := s.Refresh(ctx, reader, narrowed.RefreshToken, []string{"books:write"}); err == nil {
+		t.Error("refresh widened the scopes of the family")
+	}
+
+	// scopes taken from the client since the family started are dropped
+	res = issue(t, s, reader)
+	demoted := auth.Client{ID: reader.ID, Scopes: slices.Clone(reader.Scopes[:1])}
+	refreshed, err := s.Refresh(ctx, demoted, res.RefreshToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Scope != "books:read" {
+		t.Errorf("scope after the client lost books:write = %q, want books:read", refreshed.Scope)
+	}
+}
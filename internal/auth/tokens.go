@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RevocationList keeps the ids (jti) of access tokens revoked before they expire.
+// Entries can be forgotten once the token expired.
+type RevocationList interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RefreshToken is the stored state of an issued refresh token. Tokens obtained
+// from each other share a FamilyID, so a reused token can revoke its whole lineage.
+type RefreshToken struct {
+	Hash      string    `json:"hash"` // SHA-256 of the opaque token, the token itself is never stored
+	FamilyID  string    `json:"family_id"`
+	ClientID  string    `json:"client_id"`
+	Subject   string    `json:"sub,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"`
+
+	// AccessJTI and AccessExpiresAt identify the access token issued alongside,
+	// revoked together with the family.
+	AccessJTI       string    `json:"access_jti"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+}
+
+// RefreshStore keeps the refresh tokens.
+type RefreshStore interface {
+	SaveRefresh(ctx context.Context, t RefreshToken) error
+	FindRefresh(ctx context.Context, hash string) (RefreshToken, error)
+	// MarkRefreshUsed flags the token as used and returns it. If it was already
+	// used it returns the token along with ErrRefreshReused, atomically with
+	// respect to other callers.
+	MarkRefreshUsed(ctx context.Context, hash string) (RefreshToken, error)
+	// RevokeFamily deletes every token of the family and returns them.
+	RevokeFamily(ctx context.Context, familyID string) ([]RefreshToken, error)
+}
+
+// AuthCode is the stored state of an authorization code, waiting to be
+// exchanged for tokens by the client it was issued to.
+type AuthCode struct {
+	Hash        string    `json:"hash"` // SHA-256 of the code
+	ClientID    string    `json:"client_id"`
+	RedirectURI string    `json:"redirect_uri"`
+	Subject     string    `json:"sub"`
+	Name        string    `json:"name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Scopes      []string  `json:"scopes"`
+	Challenge   string    `json:"code_challenge"` // PKCE S256 challenge
+	Nonce       string    `json:"nonce,omitempty"`
+	AuthTime    time.Time `json:"auth_time"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CodeStore keeps the authorization codes until they are exchanged.
+type CodeStore interface {
+	SaveCode(ctx context.Context, c AuthCode) error
+	// ConsumeCode deletes the code and returns it, so it can only be exchanged once.
+	// Unknown codes return ErrCodeNotFound.
+	ConsumeCode(ctx context.Context, hash string) (AuthCode, error)
+}
+
+// HashToken returns the key refresh tokens and authorization codes are stored under.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
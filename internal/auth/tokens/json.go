@@ -0,0 +1,167 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JSON keeps the revocation list, refresh tokens and authorization codes in a
+// JSON file, rewritten atomically on every change so revocations survive a restart.
+type JSON struct {
+	mu   sync.Mutex
+	path string
+	s    state
+}
+
+// NewJSON loads the tokens file at path, starting empty if it doesn't exist.
+func NewJSON(path string) (*JSON, error) {
+	j := &JSON{path: path, s: newState()}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll: %w", err)
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &j.s); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	// a file written by hand may lack one of the maps
+	if j.s.Revoked == nil {
+		j.s.Revoked = map[string]time.Time{}
+	}
+	if j.s.Refresh == nil {
+		j.s.Refresh = map[string]auth.RefreshToken{}
+	}
+	if j.s.Codes == nil {
+		j.s.Codes = map[string]auth.AuthCode{}
+	}
+
+	return j, nil
+}
+
+// persist writes the state to a temporary file and renames it over the real one.
+func (j *JSON) persist() error {
+	tmp := j.path + ".tmp"
+
+	bytes, err := json.MarshalIndent(j.s, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(tmp, bytes, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
+
+// change applies fn and persists the result, restoring the previous state if the write fails.
+func (j *JSON) change(fn func(s *state)) error {
+	prev := j.s.clone()
+
+	j.s.prune(time.Now())
+	fn(&j.s)
+	if err := j.persist(); err != nil {
+		j.s = prev
+		return err
+	}
+
+	return nil
+}
+
+func (j *JSON) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.change(func(s *state) { s.revoke(jti, expiresAt) })
+}
+
+func (j *JSON) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.s.isRevoked(jti), nil
+}
+
+func (j *JSON) SaveRefresh(ctx context.Context, t auth.RefreshToken) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.change(func(s *state) { s.Refresh[t.Hash] = t })
+}
+
+func (j *JSON) FindRefresh(ctx context.Context, hash string) (auth.RefreshToken, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.s.find(hash)
+}
+
+func (j *JSON) MarkRefreshUsed(ctx context.Context, hash string) (auth.RefreshToken, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var (
+		t   auth.RefreshToken
+		err error
+	)
+	if perr := j.change(func(s *state) { t, err = s.markUsed(hash) }); perr != nil {
+		return auth.RefreshToken{}, perr
+	}
+
+	return t, err
+}
+
+func (j *JSON) RevokeFamily(ctx context.Context, familyID string) ([]auth.RefreshToken, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var revoked []auth.RefreshToken
+	if err := j.change(func(s *state) { revoked = s.revokeFamily(familyID) }); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+func (j *JSON) SaveCode(ctx context.Context, c auth.AuthCode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.change(func(s *state) { s.Codes[c.Hash] = c })
+}
+
+func (j *JSON) ConsumeCode(ctx context.Context, hash string) (auth.AuthCode, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var (
+		c   auth.AuthCode
+		err error
+	)
+	if perr := j.change(func(s *state) { c, err = s.consumeCode(hash) }); perr != nil {
+		return auth.AuthCode{}, perr
+	}
+
+	return c, err
+}
@@ -0,0 +1,81 @@
+package tokens
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/auth"
+	"sync"
+	"time"
+)
+
+// Memory keeps the revocation list, refresh tokens and authorization codes in
+// memory, they are lost on restart.
+type Memory struct {
+	mu sync.Mutex
+	s  state
+}
+
+func NewMemory() *Memory {
+	return &Memory{s: newState()}
+}
+
+func (m *Memory) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.s.prune(time.Now())
+	m.s.revoke(jti, expiresAt)
+	return nil
+}
+
+func (m *Memory) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.s.isRevoked(jti), nil
+}
+
+func (m *Memory) SaveRefresh(ctx context.Context, t auth.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.s.prune(time.Now())
+	m.s.Refresh[t.Hash] = t
+	return nil
+}
+
+func (m *Memory) FindRefresh(ctx context.Context, hash string) (auth.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.s.find(hash)
+}
+
+func (m *Memory) MarkRefreshUsed(ctx context.Context, hash string) (auth.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.s.markUsed(hash)
+}
+
+func (m *Memory) RevokeFamily(ctx context.Context, familyID string) ([]auth.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.s.revokeFamily(familyID), nil
+}
+
+func (m *Memory) SaveCode(ctx context.Context, c auth.AuthCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.s.prune(time.Now())
+	m.s.Codes[c.Hash] = c
+	return nil
+}
+
+func (m *Memory) ConsumeCode(ctx context.Context, hash string) (auth.AuthCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.s.consumeCode(hash)
+}
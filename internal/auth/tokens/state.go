@@ -0,0 +1,115 @@
+package tokens
+
+import (
+	"example/go-gin-library-api/internal/auth"
+	"time"
+)
+
+// state is the data shared by every backend. It isn't safe for concurrent use,
+// the backends hold their own lock around it.
+type state struct {
+	Revoked map[string]time.Time         `json:"revoked"` // jti -> expiry of the access token
+	Refresh map[string]auth.RefreshToken `json:"refresh"` // hash -> token
+	Codes   map[string]auth.AuthCode     `json:"codes"`   // hash -> authorization code
+}
+
+func newState() state {
+	return state{
+		Revoked: map[string]time.Time{},
+		Refresh: map[string]auth.RefreshToken{},
+		Codes:   map[string]auth.AuthCode{},
+	}
+}
+
+func (s *state) revoke(jti string, expiresAt time.Time) {
+	s.Revoked[jti] = expiresAt
+}
+
+func (s *state) isRevoked(jti string) bool {
+	_, ok := s.Revoked[jti]
+	return ok
+}
+
+func (s *state) find(hash string) (auth.RefreshToken, error) {
+	t, ok := s.Refresh[hash]
+	if !ok {
+		return auth.RefreshToken{}, auth.ErrRefreshNotFound
+	}
+
+	return t, nil
+}
+
+func (s *state) markUsed(hash string) (auth.RefreshToken, error) {
+	t, ok := s.Refresh[hash]
+	if !ok {
+		return auth.RefreshToken{}, auth.ErrRefreshNotFound
+	}
+
+	if t.Used {
+		return t, auth.ErrRefreshReused
+	}
+
+	t.Used = true
+	s.Refresh[hash] = t
+	return t, nil
+}
+
+func (s *state) revokeFamily(familyID string) []auth.RefreshToken {
+	var revoked []auth.RefreshToken
+	for hash, t := range s.Refresh {
+		if t.FamilyID == familyID {
+			revoked = append(revoked, t)
+			delete(s.Refresh, hash)
+		}
+	}
+
+	return revoked
+}
+
+func (s *state) consumeCode(hash string) (auth.AuthCode, error) {
+	c, ok := s.Codes[hash]
+	if !ok {
+		return auth.AuthCode{}, auth.ErrCodeNotFound
+	}
+
+	delete(s.Codes, hash)
+	return c, nil
+}
+
+// prune forgets the revoked access tokens, refresh tokens and codes that expired anyway.
+// Used refresh tokens are kept until they expire so reuse is still detected.
+func (s *state) prune(now time.Time) {
+	for jti, exp := range s.Revoked {
+		if now.After(exp) {
+			delete(s.Revoked, jti)
+		}
+	}
+
+	for hash, t := range s.Refresh {
+		if now.After(t.ExpiresAt) {
+			delete(s.Refresh, hash)
+		}
+	}
+
+	for hash, c := range s.Codes {
+		if now.After(c.ExpiresAt) {
+			delete(s.Codes, hash)
+		}
+	}
+}
+
+// clone copies s so a failed persist can be rolled back.
+func (s *state) clone() state {
+	c := newState()
+	for k, v := range s.Revoked {
+		c.Revoked[k] = v
+	}
+	for k, v := range s.Refresh {
+		c.Refresh[k] = v
+	}
+	for k, v := range s.Codes {
+		c.Codes[k] = v
+	}
+
+	return c
+}
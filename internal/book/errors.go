@@ -4,6 +4,7 @@ import "fmt"
 
 var (
 	ErrInvalidFilter   = fmt.Errorf("can't filter by author and title simultaneously")
+	ErrInvalidPrefix   = fmt.Errorf("prefix must be true or false")
 	ErrNotFound        = fmt.Errorf("book not found")
 	ErrDuplicate       = fmt.Errorf("book already exists")
 	ErrBookUnavailable = fmt.Errorf("book is not available for checkout")
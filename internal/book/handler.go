@@ -3,6 +3,7 @@ package book
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,6 +28,14 @@ func (h *Handler) FindAll(ctx *gin.Context) {
 	}
 
 	filters := BookFilters{Author: ctx.Query("author"), Title: ctx.Query("title")}
+	if p := ctx.Query("prefix"); p != "" {
+		prefix, err := strconv.ParseBool(p)
+		if err != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidPrefix.Error()})
+			return
+		}
+		filters.Prefix = prefix
+	}
 
 	books, err := h.service.FindAll(ctx, filters)
 	if err != nil {
@@ -0,0 +1,87 @@
+package book_test
+
+import (
+	"encoding/json"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	store, err := stores.NewMemory([]book.Book{
+		{ID: "b1", Title: "The Great Gatsby", Author: "F. Scott Fitzgerald", Quantity: 1},
+		{ID: "b2", Title: "War and Peace", Author: "Leo Tolstoy", Quantity: 1},
+		{ID: "b3", Title: "Anna Karenina", Author: "Leo Tolstoy", Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := book.NewHandler(book.NewService(store))
+	r := gin.New()
+	r.GET("/api/books", h.FindAll)
+	return r
+}
+
+// findAll requests GET /api/books?query and returns the ids of the books found.
+func findAll(t *testing.T, r http.Handler, query string) (int, []string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/books?"+query, nil))
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+
+	var books []book.BookResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &books); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return w.Code, ids
+}
+
+func TestFindAllFilters(t *testing.T) {
+	r := newRouter(t)
+
+	tests := []struct {
+		query string
+		code  int
+		ids   []string
+	}{
+		{"", http.StatusOK, []string{"b1", "b2", "b3"}},
+		{"title=an", http.StatusOK, []string{"b3", "b2"}},
+		{"title=an&prefix=true", http.StatusOK, []string{"b3"}},
+		{"title=an&prefix=false", http.StatusOK, []string{"b3", "b2"}},
+		{"author=LEO&prefix=1", http.StatusOK, []string{"b2", "b3"}},
+		{"author=tolstoy&prefix=true", http.StatusOK, []string{}},
+		{"title=an&prefix=maybe", http.StatusBadRequest, nil},
+		{"title=an&author=leo", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		code, ids := findAll(t, r, tt.query)
+		if code != tt.code {
+			t.Errorf("GET /api/books?%s: %d, want %d", tt.query, code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK && !slices.Equal(ids, tt.ids) {
+			t.Errorf("GET /api/books?%s = %v, want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package book
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -9,6 +10,9 @@ import (
 
 type BookFilters struct {
 	Title, Author string
+	// Prefix matches the books whose title or author starts with the filter
+	// rather than contains it.
+	Prefix bool
 }
 
 type Service interface {
@@ -34,6 +38,24 @@ func NewService(store Store) Service {
 // FindAll returns a slice of books, filtered or not.
 func (s *BookService) FindAll(ctx context.Context, filters BookFilters) ([]Book, error) {
 
+	if title := filters.Title; title != "" && filters.Prefix {
+		books, err := FindByTitlePrefix(ctx, s.store, title)
+		if err != nil {
+			return books, fmt.Errorf("book.FindByTitlePrefix: %w", err)
+		}
+
+		return books, nil
+	}
+
+	if author := filters.Author; author != "" && filters.Prefix {
+		books, err := FindByAuthorPrefix(ctx, s.store, author)
+		if err != nil {
+			return books, fmt.Errorf("book.FindByAuthorPrefix: %w", err)
+		}
+
+		return books, nil
+	}
+
 	if title := filters.Title; title != "" {
 		books, err := s.store.FindByTitle(ctx, title)
 		if err != nil {
@@ -86,19 +108,25 @@ func (s *BookService) Create(ctx context.Context, bookRequest BookRequest) (stri
 
 // Checkout retrieves an available book from the library.
 func (s *BookService) Checkout(ctx context.Context, id string) (Book, error) {
-	book, err := s.store.FindById(ctx, id)
-
-	if err != nil {
-		return book, fmt.Errorf("store.FindById: %w", err)
-	}
-
-	if book.Quantity == 0 {
-		return book, ErrBookUnavailable
+	var book Book
+	err := s.store.WithTx(ctx, func(tx Store) (err error) {
+		book, err = Modify(ctx, tx, id, func(b *Book) error {
+			if b.Quantity == 0 {
+				return ErrBookUnavailable
+			}
+
+			b.Quantity -= 1
+			return nil
+		})
+		return err
+	})
+
+	if errors.Is(err, ErrBookUnavailable) {
+		return book, err
 	}
 
-	book.Quantity -= 1
-	if err := s.store.Update(ctx, book); err != nil {
-		return book, fmt.Errorf("store.Update: %w", err)
+	if err != nil {
+		return book, fmt.Errorf("store.WithTx: %w", err)
 	}
 
 	return book, nil
@@ -106,15 +134,17 @@ func (s *BookService) Checkout(ctx context.Context, id string) (Book, error) {
 
 // Return gives back a book to the library.
 func (s *BookService) Return(ctx context.Context, id string) (Book, error) {
-	book, err := s.store.FindById(ctx, id)
+	var book Book
+	err := s.store.WithTx(ctx, func(tx Store) (err error) {
+		book, err = Modify(ctx, tx, id, func(b *Book) error {
+			b.Quantity += 1
+			return nil
+		})
+		return err
+	})
 
 	if err != nil {
-		return book, fmt.Errorf("store.FindById: %w", err)
-	}
-
-	book.Quantity += 1
-	if err := s.store.Update(ctx, book); err != nil {
-		return book, fmt.Errorf("store.Update: %w", err)
+		return book, fmt.Errorf("store.WithTx: %w", err)
 	}
 
 	return book, nil
@@ -2,13 +2,100 @@ package book
 
 import (
 	"context"
+	"io"
+	"slices"
+	"strings"
 )
 
+// Store is implemented by every book backend. Close releases the backend's
+// resources (connections, pending writes) and is called once on shutdown.
 type Store interface {
+	io.Closer
+
 	List(ctx context.Context) ([]Book, error)
 	FindById(ctx context.Context, id string) (Book, error)
 	Create(ctx context.Context, b Book) (string, error)
 	Update(ctx context.Context, b Book) error
 	FindByTitle(ctx context.Context, title string) ([]Book, error)
 	FindByAuthor(ctx context.Context, author string) ([]Book, error)
+
+	// WithTx runs fn with a store whose writes are all kept if fn returns nil,
+	// and all discarded otherwise. Calling WithTx on that store runs within the
+	// same transaction. Don't use the outer store inside fn, it may wait for the
+	// transaction to end.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Modifier is implemented by stores that can read, change and write back a book
+// atomically, e.g. with SELECT ... FOR UPDATE.
+type Modifier interface {
+	Modify(ctx context.Context, id string, fn func(*Book) error) (Book, error)
+}
+
+// Modify applies fn to the book id and saves it. Stores implementing Modifier do
+// it atomically; for the others it is a FindById followed by an Update. When fn
+// fails nothing is saved, and the book is returned as it was found.
+func Modify(ctx context.Context, store Store, id string, fn func(*Book) error) (Book, error) {
+	if m, ok := store.(Modifier); ok {
+		return m.Modify(ctx, id, fn)
+	}
+
+	found, err := store.FindById(ctx, id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	b := found
+	if err := fn(&b); err != nil {
+		return found, err
+	}
+
+	if err := store.Update(ctx, b); err != nil {
+		return found, err
+	}
+
+	return b, nil
+}
+
+// PrefixFinder is implemented by stores with ordered indexes on title and
+// author, which find the books starting with a prefix by seeking to it rather
+// than reading every book.
+type PrefixFinder interface {
+	FindByTitlePrefix(ctx context.Context, prefix string) ([]Book, error)
+	FindByAuthorPrefix(ctx context.Context, prefix string) ([]Book, error)
+}
+
+// FindByTitlePrefix returns the books whose title starts with prefix, ignoring
+// case, ordered by title. Stores implementing PrefixFinder use their index; for
+// the others the books FindByTitle finds are filtered.
+func FindByTitlePrefix(ctx context.Context, store Store, prefix string) ([]Book, error) {
+	if f, ok := store.(PrefixFinder); ok {
+		return f.FindByTitlePrefix(ctx, prefix)
+	}
+
+	books, err := store.FindByTitle(ctx, prefix)
+	return startingWith(books, err, prefix, func(b Book) string { return b.Title })
+}
+
+// FindByAuthorPrefix returns the books whose author starts with prefix, ignoring
+// case, ordered by author. See FindByTitlePrefix.
+func FindByAuthorPrefix(ctx context.Context, store Store, prefix string) ([]Book, error) {
+	if f, ok := store.(PrefixFinder); ok {
+		return f.FindByAuthorPrefix(ctx, prefix)
+	}
+
+	books, err := store.FindByAuthor(ctx, prefix)
+	return startingWith(books, err, prefix, func(b Book) string { return b.Author })
+}
+
+// startingWith keeps the books whose key starts with prefix, ignoring case.
+func startingWith(books []Book, err error, prefix string, key func(Book) string) ([]Book, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToLower(prefix)
+	return slices.DeleteFunc(books, func(b Book) bool {
+		return !strings.HasPrefix(strings.ToLower(key(b)), prefix)
+	}), nil
 }
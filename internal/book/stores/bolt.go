@@ -0,0 +1,337 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example/go-gin-library-api/internal/book"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the bolt store. Books are kept as JSON under their id; the index
+// buckets only hold keys, "<lower-cased value>\x00<id>", so they iterate in
+// value order and a prefix is a cursor seek away.
+var (
+	boltBooks       = []byte("books")
+	boltTitles      = []byte("idx_title")
+	boltAuthors     = []byte("idx_author")
+	boltTitleAuthor = []byte("idx_title_author") // "<title>\x00<author>" -> id, for duplicates
+)
+
+// Bolt keeps the books in an embedded key-value file (bbolt). Every write is a
+// transaction synced to disk before it returns, and a crash mid-write leaves
+// the file as it was at the last commit, so books and indexes always agree.
+// The file is locked, only one process can open it.
+type Bolt struct {
+	DB *bolt.DB
+}
+
+// NewBolt opens (or creates) the database at path, seeding the books when it's new.
+func NewBolt(path string, seed []book.Book) (*Bolt, error) {
+	if err := ensureDir(path); err != nil {
+		return nil, fmt.Errorf("ensureDir: %w", err)
+	}
+
+	// without a timeout Open blocks forever while another process holds the file
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("bolt.Open: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		created := tx.Bucket(boltBooks) == nil
+
+		for _, name := range [][]byte{boltBooks, boltTitles, boltAuthors, boltTitleAuthor} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		if created {
+			for _, b := range seed {
+				if err := boltPut(tx, b, nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.Update: %w", err)
+	}
+
+	return &Bolt{DB: db}, nil
+}
+
+// indexKey is the key of b in an index on value.
+func indexKey(value, id string) []byte {
+	return []byte(strings.ToLower(value) + "\x00" + id)
+}
+
+func titleAuthorKey(b book.Book) []byte {
+	return []byte(strings.ToLower(b.Title) + "\x00" + strings.ToLower(b.Author))
+}
+
+// boltGet reads the book id, ErrNotFound when missing.
+func boltGet(tx *bolt.Tx, id string) (book.Book, error) {
+	data := tx.Bucket(boltBooks).Get([]byte(id))
+	if data == nil {
+		return book.Book{}, book.ErrNotFound
+	}
+
+	var b book.Book
+	if err := json.Unmarshal(data, &b); err != nil {
+		return book.Book{}, fmt.Errorf("book %s: %w", id, err)
+	}
+
+	return b, nil
+}
+
+// boltPut writes b and its index entries, replacing the entries of old when b
+// is an update. Another book with the same title and author is ErrDuplicate.
+func boltPut(tx *bolt.Tx, b book.Book, old *book.Book) error {
+	dup := tx.Bucket(boltTitleAuthor)
+	if owner := dup.Get(titleAuthorKey(b)); owner != nil && string(owner) != b.ID {
+		return book.ErrDuplicate
+	}
+
+	titles, authors := tx.Bucket(boltTitles), tx.Bucket(boltAuthors)
+	if old != nil {
+		if err := titles.Delete(indexKey(old.Title, old.ID)); err != nil {
+			return err
+		}
+		if err := authors.Delete(indexKey(old.Author, old.ID)); err != nil {
+			return err
+		}
+		if err := dup.Delete(titleAuthorKey(*old)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(boltBooks).Put([]byte(b.ID), data); err != nil {
+		return err
+	}
+	if err := titles.Put(indexKey(b.Title, b.ID), nil); err != nil {
+		return err
+	}
+	if err := authors.Put(indexKey(b.Author, b.ID), nil); err != nil {
+		return err
+	}
+
+	return dup.Put(titleAuthorKey(b), []byte(b.ID))
+}
+
+// scanIndex walks index in order from prefix, returning the books whose
+// indexed value matches. Only the keys are read until a book matches.
+func scanIndex(tx *bolt.Tx, index []byte, prefix string, match func(value string) bool) ([]book.Book, error) {
+	out := []book.Book{}
+	seek := []byte(strings.ToLower(prefix))
+
+	c := tx.Bucket(index).Cursor()
+	for k, _ := c.Seek(seek); k != nil && bytes.HasPrefix(k, seek); k, _ = c.Next() {
+		value, id, ok := bytes.Cut(k, []byte{0})
+		if !ok || !match(string(value)) {
+			continue
+		}
+
+		b, err := boltGet(tx, string(id))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+
+	return out, nil
+}
+
+func matchAll(string) bool { return true }
+
+// List returns all the books, ordered by author.
+func (s *Bolt) List(ctx context.Context) ([]book.Book, error) {
+	var out []book.Book
+	err := s.DB.View(func(tx *bolt.Tx) (err error) {
+		out, err = boltTx{tx}.List(ctx)
+		return err
+	})
+
+	return out, err
+}
+
+// FindById reads a book by its id.
+func (s *Bolt) FindById(ctx context.Context, id string) (book.Book, error) {
+	var b book.Book
+	err := s.DB.View(func(tx *bolt.Tx) (err error) {
+		b, err = boltTx{tx}.FindById(ctx, id)
+		return err
+	})
+
+	return b, err
+}
+
+// Create writes a new book, unless one with the same id, or title and author, exists.
+func (s *Bolt) Create(ctx context.Context, b book.Book) (string, error) {
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		_, err := boltTx{tx}.Create(ctx, b)
+		return err
+	})
+
+	return b.ID, err
+}
+
+// Update replaces an existing book.
+func (s *Bolt) Update(ctx context.Context, b book.Book) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return boltTx{tx}.Update(ctx, b)
+	})
+}
+
+// Modify runs the read, fn and the write in a single transaction.
+func (s *Bolt) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	var b book.Book
+	err := s.DB.Update(func(tx *bolt.Tx) (err error) {
+		b, err = boltTx{tx}.Modify(ctx, id, fn)
+		return err
+	})
+
+	return b, err
+}
+
+// FindByTitle returns the books whose title contains title, case-insensitively, ordered by title.
+func (s *Bolt) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	return s.find(boltTitles, "", title)
+}
+
+// FindByAuthor returns the books whose author contains author, case-insensitively, ordered by author.
+func (s *Bolt) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	return s.find(boltAuthors, "", author)
+}
+
+// FindByTitlePrefix returns the books whose title starts with prefix, case-insensitively.
+// Unlike FindByTitle it only reads the matching part of the index.
+func (s *Bolt) FindByTitlePrefix(ctx context.Context, prefix string) ([]book.Book, error) {
+	return s.find(boltTitles, prefix, "")
+}
+
+// FindByAuthorPrefix returns the books whose author starts with prefix, case-insensitively.
+func (s *Bolt) FindByAuthorPrefix(ctx context.Context, prefix string) ([]book.Book, error) {
+	return s.find(boltAuthors, prefix, "")
+}
+
+// find scans index from prefix for the values containing needle.
+func (s *Bolt) find(index []byte, prefix, needle string) ([]book.Book, error) {
+	var out []book.Book
+	err := s.DB.View(func(tx *bolt.Tx) (err error) {
+		out, err = boltTx{tx}.find(index, prefix, needle)
+		return err
+	})
+
+	return out, err
+}
+
+// WithTx runs fn in a single read-write transaction. bbolt has one writer at
+// a time, so other writes wait for fn to return.
+func (s *Bolt) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+// boltTx is the store as seen from within a bbolt transaction.
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) List(ctx context.Context) ([]book.Book, error) {
+	return scanIndex(t.tx, boltAuthors, "", matchAll)
+}
+
+func (t boltTx) FindById(ctx context.Context, id string) (book.Book, error) {
+	return boltGet(t.tx, id)
+}
+
+func (t boltTx) Create(ctx context.Context, b book.Book) (string, error) {
+	if t.tx.Bucket(boltBooks).Get([]byte(b.ID)) != nil {
+		return b.ID, book.ErrDuplicate
+	}
+
+	return b.ID, boltPut(t.tx, b, nil)
+}
+
+func (t boltTx) Update(ctx context.Context, b book.Book) error {
+	old, err := boltGet(t.tx, b.ID)
+	if err != nil {
+		return err
+	}
+
+	return boltPut(t.tx, b, &old)
+}
+
+func (t boltTx) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	found, err := boltGet(t.tx, id)
+	if err != nil {
+		return book.Book{}, err
+	}
+
+	b := found
+	if err := fn(&b); err != nil {
+		return found, err
+	}
+	b.ID = found.ID
+
+	if err := boltPut(t.tx, b, &found); err != nil {
+		return found, err
+	}
+
+	return b, nil
+}
+
+func (t boltTx) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	return t.find(boltTitles, "", title)
+}
+
+func (t boltTx) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	return t.find(boltAuthors, "", author)
+}
+
+func (t boltTx) find(index []byte, prefix, needle string) ([]book.Book, error) {
+	needle = strings.ToLower(needle)
+	return scanIndex(t.tx, index, prefix, func(value string) bool {
+		return strings.Contains(value, needle)
+	})
+}
+
+// WithTx joins the transaction.
+func (t boltTx) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	return fn(t)
+}
+
+// Close does nothing, the transaction ends with WithTx.
+func (t boltTx) Close() error {
+	return nil
+}
+
+// Check reports an error once the database was closed, used by the readiness probe.
+func (s *Bolt) Check(ctx context.Context) error {
+	return s.DB.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltBooks) == nil {
+			return fmt.Errorf("books bucket is missing")
+		}
+		return nil
+	})
+}
+
+// Close releases the database file.
+func (s *Bolt) Close() error {
+	return s.DB.Close()
+}
@@ -0,0 +1,207 @@
+package stores_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBolt(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) book.Store {
+		s, err := stores.NewBolt(filepath.Join(t.TempDir(), "books.bolt"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestBoltIsPrefixFinder(t *testing.T) {
+	s, err := stores.NewBolt(filepath.Join(t.TempDir(), "books.bolt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the prefix searches of storetest seek the index rather than filtering FindByTitle
+	if _, ok := book.Store(s).(book.PrefixFinder); !ok {
+		t.Fatal("the bolt store doesn't implement book.PrefixFinder")
+	}
+}
+
+// crashStock is the number of copies shared by the two books the crash child
+// moves copies between; every move also creates a book, in the same transaction.
+const crashStock = 1000
+
+var (
+	crashFrom = book.Book{ID: "from", Title: "From", Author: "Crash", Quantity: crashStock}
+	crashTo   = book.Book{ID: "to", Title: "To", Author: "Crash"}
+)
+
+// TestBoltCrash kills a process in the middle of its writes, several times,
+// and checks after each kill that the file opens, the indexes agree with the
+// books and no transaction was half applied.
+func TestBoltCrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("kills child processes")
+	}
+
+	path := filepath.Join(t.TempDir(), "books.bolt")
+
+	for round := range 8 {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestBoltCrashChild$")
+		cmd.Env = append(os.Environ(), "BOLT_CRASH_PATH="+path)
+		cmd.Stderr = os.Stderr
+
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		// wait until the child is writing, then kill it at a random point
+		lines := bufio.NewScanner(out)
+		for lines.Scan() && lines.Text() != "writing" {
+		}
+		time.Sleep(time.Duration(rand.IntN(30)) * time.Millisecond)
+
+		if err := cmd.Process.Kill(); err != nil {
+			t.Fatal(err)
+		}
+		cmd.Wait()
+
+		moved := checkBoltFile(t, path)
+		t.Logf("round %d: killed after %d moves", round, moved)
+	}
+}
+
+// TestBoltCrashChild is the process TestBoltCrash kills: it moves copies and
+// retitles books until it is killed.
+func TestBoltCrashChild(t *testing.T) {
+	path := os.Getenv("BOLT_CRASH_PATH")
+	if path == "" {
+		t.Skip("run by TestBoltCrash")
+	}
+
+	s, err := stores.NewBolt(path, []book.Book{crashFrom, crashTo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	fmt.Println("writing")
+
+	for deadline := time.Now().Add(time.Minute); time.Now().Before(deadline); {
+		err := s.WithTx(ctx, func(tx book.Store) error {
+			from, err := book.Modify(ctx, tx, crashFrom.ID, func(b *book.Book) error {
+				if b.Quantity == 0 {
+					return book.ErrBookUnavailable
+				}
+				b.Quantity--
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if _, err := book.Modify(ctx, tx, crashTo.ID, func(b *book.Book) error { b.Quantity++; return nil }); err != nil {
+				return err
+			}
+
+			n := crashStock - from.Quantity
+			_, err = tx.Create(ctx, book.Book{ID: fmt.Sprintf("moved-%d", n), Title: fmt.Sprintf("Move %d", n), Author: "Crash"})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// retitling moves the book's keys in every index
+		to, err := s.FindById(ctx, crashTo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to.Title = fmt.Sprintf("To %d", to.Quantity)
+		if err := s.Update(ctx, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkBoltFile verifies the file left by a killed child and returns how many
+// copies were moved.
+func checkBoltFile(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("reopening after the kill: %v", err)
+	}
+	defer db.Close()
+
+	books := map[string]book.Book{}
+	err = db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte("books")).ForEach(func(k, v []byte) error {
+			var b book.Book
+			if err := json.Unmarshal(v, &b); err != nil {
+				return fmt.Errorf("book %s: %w", k, err)
+			}
+			books[string(k)] = b
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// every book has exactly one key in each index, and no key is left over
+		indexes := map[string]func(book.Book) string{
+			"idx_title":        func(b book.Book) string { return strings.ToLower(b.Title) + "\x00" + b.ID },
+			"idx_author":       func(b book.Book) string { return strings.ToLower(b.Author) + "\x00" + b.ID },
+			"idx_title_author": func(b book.Book) string { return strings.ToLower(b.Title) + "\x00" + strings.ToLower(b.Author) },
+		}
+		for name, key := range indexes {
+			bucket := tx.Bucket([]byte(name))
+			if n := bucket.Stats().KeyN; n != len(books) {
+				return fmt.Errorf("%s has %d keys for %d books", name, n, len(books))
+			}
+
+			for _, b := range books {
+				v := bucket.Get([]byte(key(b)))
+				if v == nil {
+					return fmt.Errorf("%s has no key for %+v", name, b)
+				}
+				if name == "idx_title_author" && !bytes.Equal(v, []byte(b.ID)) {
+					return fmt.Errorf("%s points %+v at %s", name, b, v)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := books[crashFrom.ID], books[crashTo.ID]
+	moved := crashStock - from.Quantity
+	if to.Quantity != moved || len(books) != 2+moved {
+		t.Fatalf("half applied transaction: from has %d copies, to %d, with %d books", from.Quantity, to.Quantity, len(books))
+	}
+
+	return moved
+}
@@ -6,39 +6,72 @@ import (
 	"errors"
 	"example/go-gin-library-api/internal/book"
 	"fmt"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 )
 
+// compactEvery is how many log records are kept before they are folded into
+// the JSON file.
+const compactEvery = 1000
+
+// JSON keeps the books in memory, backed by a JSON file and a write-ahead log
+// next to it (path + ".wal"). Every write is appended to the log and synced
+// before it is acknowledged; the file is rewritten only on compaction.
 type JSON struct {
-	mu   sync.Mutex // Allows one go routine at a time to access a critical section.
-	path string
-	data map[string]book.Book
+	mu      sync.Mutex // Allows one go routine at a time to access a critical section.
+	path    string
+	items   bookMap
+	wal     *os.File
+	records int // in the log since the last compaction
 }
 
 // (j *JSON) is my receiver, which can be used to call these functions
-// NewJSON creates a JSONstore.
+// NewJSON loads the JSON file at path and replays the log on top of it. If
+// neither exists yet, the file is created with the seed.
 func NewJSON(path string, seed []book.Book) (*JSON, error) {
 	j := &JSON{
-		path: path,
-		data: map[string]book.Book{},
+		path:  path,
+		items: bookMap{items: map[string]book.Book{}},
 	}
 
 	if err := ensureDir(path); err != nil {
 		return nil, fmt.Errorf("ensureDir: %w", err)
 	}
 
-	created, err := ensureFileWithSeed(path, seed, j)
+	fresh, err := isFresh(path)
 	if err != nil {
-		return nil, fmt.Errorf("ensureFileWithSeed: %w", err)
+		return nil, fmt.Errorf("isFresh: %w", err)
 	}
 
-	if !created {
-		if err := loadFromFile(path, j); err != nil {
-			return nil, fmt.Errorf("loadFromFile: %w", err)
+	if fresh {
+		for _, b := range seed {
+			j.items.put(b)
+		}
+
+		if err := writeSnapshot(path, j.items.items); err != nil {
+			return nil, fmt.Errorf("writeSnapshot: %w", err)
 		}
+	} else if err := loadFromFile(path, j); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loadFromFile: %w", err)
+	}
+
+	if j.wal, err = os.OpenFile(path+".wal", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	if j.records, err = replayLog(j.wal, j.items.items); err != nil {
+		j.wal.Close()
+		return nil, fmt.Errorf("replayLog: %w", err)
+	}
+
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		j.wal.Close()
+		return nil, fmt.Errorf("syncDir: %w", err)
 	}
 
 	return j, nil
@@ -56,49 +89,71 @@ func ensureDir(path string) error {
 	return nil
 }
 
-// ensureFileWithSeed creates the file with the seed if necessary, and returns a bool (created) to confirm creation.
-func ensureFileWithSeed(path string, seed []book.Book, j *JSON) (bool, error) {
-	// load the file from dir
-	_, err := os.Stat(path)
-
-	// use seed to create the file if it doesn't exist
-	if errors.Is(err, os.ErrNotExist) {
-		for _, b := range seed {
-			j.data[b.ID] = b
+// isFresh reports whether neither the file nor its log exist, so the store
+// has never been written to.
+func isFresh(path string) (bool, error) {
+	for _, p := range []string{path, path + ".wal"} {
+		_, err := os.Stat(p)
+		if err == nil {
+			return false, nil
 		}
 
-		if err := j.persist(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
 			return false, err
 		}
+	}
+
+	return true, nil
+}
+
+// write logs the books and then puts them. Within a WithTx fn they are only
+// put in its overlay, and logged together once it succeeds.
+func (j *JSON) write(books ...book.Book) error {
+	if !j.items.inTx() {
+		if err := appendRecord(j.wal, books); err != nil {
+			return err
+		}
+		j.records++
+	}
 
-		return true, nil
+	for _, b := range books {
+		j.items.put(b)
 	}
 
-	if err != nil {
-		return false, err
+	if j.items.inTx() {
+		return nil
 	}
 
-	// no file created, data is not yest inside j.data
-	return false, nil
+	// the write is in the log already, a failed compaction is retried on the next one
+	if j.records >= compactEvery {
+		if err := j.compact(); err != nil {
+			log.Printf("Compacting %s failed: %v", j.path, err)
+		}
+	}
+
+	return nil
 }
 
-// persist writes the file atomically if it doesn't exist on the path.
-func (j *JSON) persist() error {
-	tmp := j.path + ".tmp"
+// compact writes the books to the file and empties the log, which the file
+// now includes. A crash in between replays the log again, which does no harm.
+func (j *JSON) compact() error {
+	if err := writeSnapshot(j.path, j.items.items); err != nil {
+		return err
+	}
 
-	bytes, err := json.MarshalIndent(j.data, "", " ")
-	if err != nil {
+	if err := j.wal.Truncate(0); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(tmp, bytes, 0o644); err != nil {
+	if err := j.wal.Sync(); err != nil {
 		return err
 	}
 
-	return os.Rename(tmp, j.path)
+	j.records = 0
+	return nil
 }
 
-// loadFromFile reads the Json file from path and unmarshalls the content to the data map on JSON struct.
+// loadFromFile reads the Json file from path and unmarshalls the content to the books of the JSON struct.
 func loadFromFile(path string, j *JSON) error {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
@@ -106,7 +161,7 @@ func loadFromFile(path string, j *JSON) error {
 	}
 
 	if len(bytes) > 0 {
-		err := json.Unmarshal(bytes, &j.data)
+		err := json.Unmarshal(bytes, &j.items.items)
 		if err != nil {
 			return err
 		}
@@ -120,11 +175,9 @@ func (j *JSON) List(ctx context.Context) ([]book.Book, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	out := make([]book.Book, 0, len(j.data))
-	for _, b := range j.data {
-		out = append(out, b)
-	}
+	out := slices.AppendSeq(make([]book.Book, 0), j.items.all())
 
+	sortBy(out, byAuthor)
 	return out, nil
 }
 
@@ -133,7 +186,7 @@ func (j *JSON) FindById(ctx context.Context, id string) (book.Book, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	b, exists := j.data[id]
+	b, exists := j.items.get(id)
 
 	if !exists {
 		return book.Book{}, book.ErrNotFound
@@ -147,18 +200,11 @@ func (j *JSON) Create(ctx context.Context, b book.Book) (string, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	if _, exists := j.data[b.ID]; exists {
+	if _, exists := j.items.get(b.ID); exists || j.items.duplicate(b) {
 		return b.ID, book.ErrDuplicate
 	}
 
-	for _, current := range j.data {
-		if strings.EqualFold(current.Title, b.Title) && strings.EqualFold(current.Author, b.Author) {
-			return b.ID, book.ErrDuplicate
-		}
-	}
-
-	j.data[b.ID] = b
-	return b.ID, j.persist()
+	return b.ID, j.write(b)
 }
 
 // Update offers thread-safe writing in memory for an existing book (found by ID).
@@ -166,13 +212,43 @@ func (j *JSON) Update(ctx context.Context, b book.Book) error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	_, exists := j.data[b.ID]
+	found, exists := j.items.get(b.ID)
 	if !exists {
 		return book.ErrNotFound
 	}
 
-	j.data[b.ID] = b
-	return j.persist()
+	if renamed(found, b) && j.items.duplicate(b) {
+		return book.ErrDuplicate
+	}
+
+	return j.write(b)
+}
+
+// Modify runs the read, fn and the write under the lock.
+func (j *JSON) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	found, exists := j.items.get(id)
+	if !exists {
+		return book.Book{}, book.ErrNotFound
+	}
+
+	b := found
+	if err := fn(&b); err != nil {
+		return found, err
+	}
+	b.ID = found.ID
+
+	if renamed(found, b) && j.items.duplicate(b) {
+		return found, book.ErrDuplicate
+	}
+
+	if err := j.write(b); err != nil {
+		return found, err
+	}
+
+	return b, nil
 }
 
 // FindByTitle returns a slice of books found by a title.
@@ -181,14 +257,15 @@ func (j *JSON) FindByTitle(ctx context.Context, title string) ([]book.Book, erro
 	defer j.mu.Unlock()
 
 	needle := strings.ToLower(title)
-	var out = make([]book.Book, 0, len(j.data))
+	var out = make([]book.Book, 0)
 
-	for _, current := range j.data {
+	for current := range j.items.all() {
 		if strings.Contains(strings.ToLower(current.Title), needle) {
 			out = append(out, current)
 		}
 	}
 
+	sortBy(out, byTitle)
 	return out, nil
 }
 
@@ -198,13 +275,67 @@ func (j *JSON) FindByAuthor(ctx context.Context, author string) ([]book.Book, er
 	defer j.mu.Unlock()
 
 	needle := strings.ToLower(author)
-	var out = make([]book.Book, 0, len(j.data))
+	var out = make([]book.Book, 0)
 
-	for _, current := range j.data {
+	for current := range j.items.all() {
 		if strings.Contains(strings.ToLower(current.Author), needle) {
 			out = append(out, current)
 		}
 	}
 
+	sortBy(out, byAuthor)
 	return out, nil
 }
+
+// WithTx hands fn an overlay of the books, whose writes are logged as a single
+// record if fn succeeds. The store is locked meanwhile.
+func (j *JSON) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	if j.items.inTx() {
+		return fn(j)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	tx := &JSON{path: j.path, items: j.items.overlay()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(tx.items.items) == 0 {
+		return nil
+	}
+
+	return j.write(slices.Collect(maps.Values(tx.items.items))...)
+}
+
+// Check verifies the log can still be opened for writing, used by the readiness probe.
+func (j *JSON) Check(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path+".wal", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Close compacts the log into the file and closes it. The store of a WithTx
+// fn has nothing to close.
+func (j *JSON) Close() error {
+	if j.items.inTx() {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.compact(); err != nil {
+		j.wal.Close()
+		return err
+	}
+
+	return j.wal.Close()
+}
@@ -0,0 +1,19 @@
+package stores_test
+
+import (
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) book.Store {
+		s, err := stores.NewJSON(filepath.Join(t.TempDir(), "books.json"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
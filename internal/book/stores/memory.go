@@ -1,24 +1,28 @@
 package stores
 
 import (
+	"cmp"
 	"context"
 	"example/go-gin-library-api/internal/book"
+	"iter"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 )
 
 // Fastest way to get going, stores items on a map. Thread-safe with a RWMutex.
 type Memory struct {
-	mu    sync.RWMutex         // embedding a value of type sync.RWMutex, not a pointer. That type is ready to use in its zero value.
-	items map[string]book.Book // key is the book id
+	mu    sync.RWMutex // embedding a value of type sync.RWMutex, not a pointer. That type is ready to use in its zero value.
+	items bookMap
 }
 
 // NewMemory creates a MemoryStore
 func NewMemory(seed []book.Book) (*Memory, error) {
-	m := &Memory{items: make(map[string]book.Book, len(seed))}
+	m := &Memory{items: bookMap{items: make(map[string]book.Book, len(seed))}}
 
 	for _, b := range seed {
-		m.items[b.ID] = b
+		m.items.put(b)
 	}
 
 	return m, nil
@@ -30,11 +34,9 @@ func (m *Memory) List(ctx context.Context) ([]book.Book, error) {
 	m.mu.RLock()         //locks for writes, but keep reads going
 	defer m.mu.RUnlock() // defer runs the code when the concurrent function return (no matter the result)
 
-	out := make([]book.Book, 0, len(m.items))
-	for _, b := range m.items {
-		out = append(out, b)
-	}
+	out := slices.AppendSeq(make([]book.Book, 0), m.items.all())
 
+	sortBy(out, byAuthor)
 	return out, nil
 }
 
@@ -43,7 +45,7 @@ func (m *Memory) FindById(ctx context.Context, id string) (book.Book, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	b, ok := m.items[id]
+	b, ok := m.items.get(id)
 	if !ok {
 		return book.Book{}, book.ErrNotFound
 	}
@@ -56,17 +58,11 @@ func (m *Memory) Create(ctx context.Context, b book.Book) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.items[b.ID]; exists {
+	if _, exists := m.items.get(b.ID); exists || m.items.duplicate(b) {
 		return b.ID, book.ErrDuplicate
 	}
 
-	for _, current := range m.items {
-		if strings.EqualFold(current.Title, b.Title) && strings.EqualFold(current.Author, b.Author) {
-			return b.ID, book.ErrDuplicate
-		}
-	}
-
-	m.items[b.ID] = b
+	m.items.put(b)
 	return b.ID, nil
 }
 
@@ -75,14 +71,43 @@ func (m *Memory) Update(ctx context.Context, b book.Book) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.items[b.ID]; !exists {
+	found, exists := m.items.get(b.ID)
+	if !exists {
 		return book.ErrNotFound
 	}
 
-	m.items[b.ID] = b
+	if renamed(found, b) && m.items.duplicate(b) {
+		return book.ErrDuplicate
+	}
+
+	m.items.put(b)
 	return nil
 }
 
+// Modify runs the read, fn and the write under the lock.
+func (m *Memory) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found, exists := m.items.get(id)
+	if !exists {
+		return book.Book{}, book.ErrNotFound
+	}
+
+	b := found
+	if err := fn(&b); err != nil {
+		return found, err
+	}
+	b.ID = found.ID
+
+	if renamed(found, b) && m.items.duplicate(b) {
+		return found, book.ErrDuplicate
+	}
+
+	m.items.put(b)
+	return b, nil
+}
+
 // FindByTitle returns a slice of books found by a title.
 func (m *Memory) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
 	m.mu.RLock()
@@ -91,12 +116,13 @@ func (m *Memory) FindByTitle(ctx context.Context, title string) ([]book.Book, er
 	needle := strings.ToLower(title)
 	out := make([]book.Book, 0)
 
-	for _, b := range m.items {
+	for b := range m.items.all() {
 		if strings.Contains(strings.ToLower(b.Title), needle) {
 			out = append(out, b)
 		}
 	}
 
+	sortBy(out, byTitle)
 	return out, nil
 }
 
@@ -108,11 +134,113 @@ func (m *Memory) FindByAuthor(ctx context.Context, author string) ([]book.Book,
 	needle := strings.ToLower(author)
 	out := make([]book.Book, 0)
 
-	for _, b := range m.items {
+	for b := range m.items.all() {
 		if strings.Contains(strings.ToLower(b.Author), needle) {
 			out = append(out, b)
 		}
 	}
 
+	sortBy(out, byAuthor)
 	return out, nil
 }
+
+// WithTx hands fn an overlay of the books, whose writes are copied in if fn
+// succeeds. The store is locked meanwhile, so transactions run one at a time.
+func (m *Memory) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	if m.items.inTx() {
+		return fn(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &Memory{items: m.items.overlay()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	maps.Copy(m.items.items, tx.items.items)
+	return nil
+}
+
+// bookMap holds the books of the memory and JSON stores. The one handed to a
+// WithTx fn is an overlay: it keeps only the books the fn wrote and reads the
+// rest from the store's map, which the store's lock keeps still meanwhile.
+type bookMap struct {
+	items map[string]book.Book // key is the book id
+	base  map[string]book.Book // the store's books under an overlay, nil otherwise
+}
+
+// overlay returns an empty overlay on top of s.
+func (s bookMap) overlay() bookMap {
+	return bookMap{items: map[string]book.Book{}, base: s.items}
+}
+
+func (s bookMap) inTx() bool { return s.base != nil }
+
+func (s bookMap) get(id string) (book.Book, bool) {
+	if b, ok := s.items[id]; ok {
+		return b, true
+	}
+
+	b, ok := s.base[id]
+	return b, ok
+}
+
+func (s bookMap) put(b book.Book) { s.items[b.ID] = b }
+
+// all yields every book once, the overlay's version of those it wrote.
+func (s bookMap) all() iter.Seq[book.Book] {
+	return func(yield func(book.Book) bool) {
+		for _, b := range s.items {
+			if !yield(b) {
+				return
+			}
+		}
+
+		for id, b := range s.base {
+			if _, written := s.items[id]; written {
+				continue
+			}
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// duplicate reports whether another book has the title and author of b, ignoring case.
+func (s bookMap) duplicate(b book.Book) bool {
+	for current := range s.all() {
+		if current.ID != b.ID && strings.EqualFold(current.Title, b.Title) && strings.EqualFold(current.Author, b.Author) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renamed reports whether b changes the title or author of was, so a write
+// that only moves copies doesn't scan every book for a duplicate.
+func renamed(was, b book.Book) bool {
+	return !strings.EqualFold(was.Title, b.Title) || !strings.EqualFold(was.Author, b.Author)
+}
+
+func byTitle(b book.Book) string  { return b.Title }
+func byAuthor(b book.Book) string { return b.Author }
+
+// sortBy orders books by key ignoring case, like the SQL stores, then by id so
+// the order is stable between calls.
+func sortBy(books []book.Book, key func(book.Book) string) {
+	slices.SortFunc(books, func(a, b book.Book) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(key(a)), strings.ToLower(key(b))),
+			strings.Compare(a.ID, b.ID),
+		)
+	})
+}
+
+// Close is a no-op, there is nothing to release for an in-memory store.
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package stores_test
+
+import (
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) book.Store {
+		s, err := stores.NewMemory(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
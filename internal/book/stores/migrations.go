@@ -0,0 +1,21 @@
+package stores
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// migrations holds the schema of the SQL stores, one directory per database.
+// Add a new numbered file to change a schema; never edit an applied one.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// migrationsOf returns the migrations directory of a database.
+func migrationsOf(dir string) fs.FS {
+	sub, err := fs.Sub(migrations, "migrations/"+dir)
+	if err != nil {
+		panic(err) // the directories are embedded, see above
+	}
+	return sub
+}
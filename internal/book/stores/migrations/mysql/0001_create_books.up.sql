@@ -0,0 +1,10 @@
+-- baseline, same as go-gin-library-infra/db/init.sql: a no-op on databases it created
+CREATE TABLE IF NOT EXISTS Books (
+    ID varchar(36) NOT NULL,
+    Title varchar(255),
+    Author varchar(255),
+    Quantity int,
+    PRIMARY KEY (ID),
+    INDEX idx_author (Author),
+    INDEX idx_title (Title)
+);
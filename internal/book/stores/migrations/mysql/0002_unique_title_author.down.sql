@@ -0,0 +1 @@
+ALTER TABLE Books DROP INDEX ux_title_author;
@@ -0,0 +1,3 @@
+-- the duplicate rule of every store (same title and author), enforced by the database;
+-- the default collation ignores case. Fails if the table already holds duplicates.
+ALTER TABLE Books ADD UNIQUE INDEX ux_title_author (Title, Author);
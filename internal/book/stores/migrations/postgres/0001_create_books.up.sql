@@ -0,0 +1,11 @@
+-- unquoted names fold to lower case; the unique index enforces the duplicate rule
+-- of the other stores (same title and author, ignoring case) in the database itself
+CREATE TABLE IF NOT EXISTS books (
+    id varchar(36) PRIMARY KEY,
+    title varchar(255) NOT NULL,
+    author varchar(255) NOT NULL,
+    quantity integer NOT NULL DEFAULT 0 CHECK (quantity >= 0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS books_title_author_key ON books (lower(title), lower(author));
+CREATE INDEX IF NOT EXISTS idx_author ON books (author);
+CREATE INDEX IF NOT EXISTS idx_title ON books (title);
@@ -0,0 +1,10 @@
+-- the Books table of go-gin-library-infra/db, so every query is written the same way as for MySQL
+CREATE TABLE IF NOT EXISTS Books (
+    ID varchar(36) NOT NULL,
+    Title varchar(255),
+    Author varchar(255),
+    Quantity int,
+    PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS idx_author ON Books (Author);
+CREATE INDEX IF NOT EXISTS idx_title ON Books (Title);
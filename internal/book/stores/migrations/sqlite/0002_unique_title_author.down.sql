@@ -0,0 +1 @@
+DROP INDEX ux_title_author;
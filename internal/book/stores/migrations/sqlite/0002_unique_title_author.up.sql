@@ -0,0 +1,2 @@
+-- the duplicate rule of every store (same title and author, ignoring case), enforced by the database
+CREATE UNIQUE INDEX IF NOT EXISTS ux_title_author ON Books (Title COLLATE NOCASE, Author COLLATE NOCASE);
@@ -5,18 +5,34 @@ import (
 	"database/sql"
 	"errors"
 	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/migrate"
 	"fmt"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// mysqlDupEntry is the MySQL error number for a duplicate key.
+const mysqlDupEntry = 1062
+
 type MySQL struct {
-	DB *sql.DB
+	DB     *sql.DB
+	tx     *sql.Tx // set on the store handed to a WithTx fn
+	tracer trace.Tracer
 }
 
 // NewMySQL creates a SQLiteStore.
 func NewMySQL(dsn string) (*MySQL, error) {
-	db, err := sql.Open("mysql", dsn)
+	dsnCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql.ParseDSN: %w", err)
+	}
+	dsnCfg.ParseTime = true       // schema_migrations.applied_at
+	dsnCfg.ClientFoundRows = true // RowsAffected counts matched rows, so Update can tell a missing book
+
+	db, err := sql.Open("mysql", dsnCfg.FormatDSN())
 	if err != nil {
 		return nil, fmt.Errorf("sql.Open: %w", err)
 	}
@@ -27,18 +43,36 @@ func NewMySQL(dsn string) (*MySQL, error) {
 	}
 
 	s := &MySQL{
-		DB: db,
+		DB:     db,
+		tracer: otel.Tracer("example/go-gin-library-api/internal/book/stores/mysql"),
 	}
 
 	return s, nil
 }
 
+// Migrator applies the schema migrations of migrations/mysql.
+func (s *MySQL) Migrator() (*migrate.Migrator, error) {
+	return migrate.New(s.DB, migrate.MySQL, migrationsOf("mysql"))
+}
+
+// startSpan opens a span for a single statement, carrying the SQL text as attribute.
+func (s *MySQL) startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "mysql."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNameMySQL,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	))
+}
+
 // (s *SQLite) is my receiver, which can be used to call these functions
 // List offers reading for all the current stored books. Returns a slice of books.
 func (s *MySQL) List(ctx context.Context) ([]book.Book, error) {
 	const q = `SELECT id, title, author, quantity FROM Books
-				ORDER BY author;`
-	rows, err := s.DB.QueryContext(ctx, q)
+				ORDER BY author, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	rows, err := s.q().QueryContext(ctx, q)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +97,10 @@ func (s *MySQL) List(ctx context.Context) ([]book.Book, error) {
 // FindById queries a book by its id.
 func (s *MySQL) FindById(ctx context.Context, id string) (book.Book, error) {
 	const q = `SELECT id, title, author, quantity FROM Books where id=?;`
-	row := s.DB.QueryRowContext(ctx, q, id) // QueryRowContext runs a query supposed to bring only one result
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	row := s.q().QueryRowContext(ctx, q, id) // QueryRowContext runs a query supposed to bring only one result
 
 	var b book.Book
 	if err := row.Scan(&b.ID, &b.Title, &b.Author, &b.Quantity); err != nil {
@@ -77,44 +114,120 @@ func (s *MySQL) FindById(ctx context.Context, id string) (book.Book, error) {
 	return b, nil
 }
 
-// Create writes a new book.
+// Create writes a new book. The unique index on title and author (migration
+// 0002) catches a duplicate created concurrently, after the check.
 func (s *MySQL) Create(ctx context.Context, b book.Book) (string, error) {
 	const q = `INSERT INTO Books (id, title, author, quantity)
 				VALUES (?, ?, ?, ?);`
+	ctx, span := s.startSpan(ctx, "INSERT", q)
+	defer span.End()
+
+	books, err := s.FindByTitleAndAuthor(ctx, b.Title, b.Author)
+	if err != nil {
+		return "", err
+	}
 
-	books, _ := s.FindByTitleAndAuthor(ctx, b.Title, b.Author)
 	if len(books) > 0 {
-		return "", book.ErrDuplicate
+		return b.ID, book.ErrDuplicate
+	}
+
+	_, err = s.q().ExecContext(ctx, q, b.ID, b.Title, b.Author, b.Quantity)
+	if isDupEntry(err) {
+		return b.ID, book.ErrDuplicate
 	}
 
-	if _, err := s.DB.ExecContext(ctx, q, b.ID, b.Title, b.Author, b.Quantity); err != nil {
+	if err != nil {
 		return "", err
 	}
 
 	return b.ID, nil
 }
 
-// Update makes an uodate on an existing book.
+// Update makes an update on an existing book.
 func (s *MySQL) Update(ctx context.Context, b book.Book) error {
 	const q = `UPDATE Books
 				SET title=?, author=?, quantity=?
 				WHERE id=?;`
+	ctx, span := s.startSpan(ctx, "UPDATE", q)
+	defer span.End()
 
-	if _, err := s.DB.ExecContext(ctx, q, b.Title, b.Author, b.Quantity, b.ID); err != nil {
+	res, err := s.q().ExecContext(ctx, q, b.Title, b.Author, b.Quantity, b.ID)
+	if isDupEntry(err) {
+		return book.ErrDuplicate
+	}
+
+	if err != nil {
 		return err
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return book.ErrNotFound
+	}
+
 	return nil
 }
 
+// Modify locks the row with SELECT ... FOR UPDATE until fn's changes are
+// written, so concurrent checkouts of the last copy can't both succeed.
+func (s *MySQL) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	const (
+		sel = `SELECT id, title, author, quantity FROM Books WHERE id=? FOR UPDATE;`
+		upd = `UPDATE Books SET title=?, author=?, quantity=? WHERE id=?;`
+	)
+	ctx, span := s.startSpan(ctx, "SELECT", sel)
+	defer span.End()
+
+	var found, b book.Book
+	err := inTx(ctx, s.DB, s.tx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, sel, id).Scan(&found.ID, &found.Title, &found.Author, &found.Quantity)
+		if errors.Is(err, sql.ErrNoRows) {
+			return book.ErrNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		b = found
+		if err := fn(&b); err != nil {
+			return err
+		}
+		b.ID = found.ID
+
+		_, err = tx.ExecContext(ctx, upd, b.Title, b.Author, b.Quantity, b.ID)
+		if isDupEntry(err) {
+			return book.ErrDuplicate
+		}
+
+		return err
+	})
+	if err != nil {
+		return found, err
+	}
+
+	return b, nil
+}
+
+func isDupEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDupEntry
+}
+
 // FindByTitle returns a slice of books found by a title.
 func (s *MySQL) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
-	const q = `SELECT * from Books
+	// backslash is the default LIKE escape of MySQL
+	const q = `SELECT id, title, author, quantity FROM Books
 				WHERE title LIKE ?
-				ORDER BY title;`
+				ORDER BY title, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
 
-	like := "%" + title + "%"
-	rows, err := s.DB.QueryContext(ctx, q, like)
+	rows, err := s.q().QueryContext(ctx, q, containsPattern(title))
 	if err != nil {
 		return nil, err
 	}
@@ -129,17 +242,22 @@ func (s *MySQL) FindByTitle(ctx context.Context, title string) ([]book.Book, err
 		out = append(out, b)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return out, nil
 }
 
 // FindByAuthor returns a slice of books found by an author.
 func (s *MySQL) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
-	const q = `SELECT * from Books
+	const q = `SELECT id, title, author, quantity FROM Books
 				WHERE author LIKE ?
-				ORDER BY author;`
+				ORDER BY author, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
 
-	like := "%" + author + "%"
-	rows, err := s.DB.QueryContext(ctx, q, like)
+	rows, err := s.q().QueryContext(ctx, q, containsPattern(author))
 	if err != nil {
 		return nil, err
 	}
@@ -154,17 +272,23 @@ func (s *MySQL) FindByAuthor(ctx context.Context, author string) ([]book.Book, e
 		out = append(out, b)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return out, nil
 }
 
-// FindByAuthor returns a slice of books found by title and author (matches exactly).
+// FindByTitleAndAuthor returns a slice of books found by title and author (matches exactly).
 func (s *MySQL) FindByTitleAndAuthor(ctx context.Context, title, author string) ([]book.Book, error) {
-	const q = `SELECT * from Books
+	const q = `SELECT id, title, author, quantity FROM Books
 				WHERE title=?
-				AND author=? 
+				AND author=?
 				ORDER BY author;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
 
-	rows, err := s.DB.QueryContext(ctx, q, title, author)
+	rows, err := s.q().QueryContext(ctx, q, title, author)
 	if err != nil {
 		return nil, err
 	}
@@ -179,5 +303,39 @@ func (s *MySQL) FindByTitleAndAuthor(ctx context.Context, title, author string)
 		out = append(out, b)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return out, nil
 }
+
+// WithTx runs fn within a database transaction.
+func (s *MySQL) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	return inTx(ctx, s.DB, s.tx, func(tx *sql.Tx) error {
+		return fn(&MySQL{DB: s.DB, tx: tx, tracer: s.tracer})
+	})
+}
+
+// q runs the statements within the transaction, if there is one.
+func (s *MySQL) q() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *MySQL) Check(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// Close closes the underlying connection pool. The store of a WithTx fn leaves it open.
+func (s *MySQL) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+
+	return s.DB.Close()
+}
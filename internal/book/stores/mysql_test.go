@@ -0,0 +1,69 @@
+package stores_test
+
+import (
+	"context"
+	"database/sql"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TestMySQL runs the conformance suite against the server at
+// BOOK_MYSQL_TEST_DSN, e.g. "root:secret@tcp(localhost:3306)/" with the
+// docker compose database, in a new database per subtest.
+func TestMySQL(t *testing.T) {
+	dsn := os.Getenv("BOOK_MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("BOOK_MYSQL_TEST_DSN isn't set")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	ctx := context.Background()
+	var databases atomic.Int32
+	storetest.Run(t, func(t *testing.T) book.Store {
+		name := fmt.Sprintf("books_test_%d_%d", os.Getpid(), databases.Add(1))
+		if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.ExecContext(ctx, "DROP DATABASE "+name); err != nil {
+				t.Errorf("DROP DATABASE %s: %v", name, err)
+			}
+		})
+
+		db := cfg.Clone()
+		db.DBName = name
+
+		s, err := stores.NewMySQL(db.FormatDSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := s.Migrator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
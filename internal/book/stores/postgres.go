@@ -0,0 +1,313 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/migrate"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation.
+const pgUniqueViolation = "23505"
+
+// Postgres keeps the books in a PostgreSQL database. Any server reachable by
+// DSN will do, e.g. an embedded Postgres binary started by a test.
+type Postgres struct {
+	Pool   *pgxpool.Pool
+	tx     pgx.Tx // set on the store handed to a WithTx fn
+	tracer trace.Tracer
+}
+
+// pgQuerier is what the pool and a transaction have in common.
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// NewPostgres connects to the database at dsn. Its schema comes from Migrator.
+func NewPostgres(dsn string) (*Postgres, error) {
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.New: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("pool.Ping: %w", err)
+	}
+
+	s := &Postgres{
+		Pool:   pool,
+		tracer: otel.Tracer("example/go-gin-library-api/internal/book/stores/postgres"),
+	}
+
+	return s, nil
+}
+
+// Migrator applies the schema migrations of migrations/postgres.
+func (s *Postgres) Migrator() (*migrate.Migrator, error) {
+	// closing this *sql.DB wouldn't close the pool, and it keeps no idle connections of its own
+	return migrate.New(stdlib.OpenDBFromPool(s.Pool), migrate.Postgres, migrationsOf("postgres"))
+}
+
+// startSpan opens a span for a single statement, carrying the SQL text as attribute.
+func (s *Postgres) startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "postgres."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	))
+}
+
+// List offers reading for all the current stored books.
+func (s *Postgres) List(ctx context.Context) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM books
+				ORDER BY lower(author), id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q)
+}
+
+// FindById queries a book by its id.
+func (s *Postgres) FindById(ctx context.Context, id string) (book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM books WHERE id=$1;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return scanBook(s.q().QueryRow(ctx, q, id))
+}
+
+// Create writes a new book. The primary key and the unique index reject a
+// duplicate id, or title and author, so there is no check to race with.
+func (s *Postgres) Create(ctx context.Context, b book.Book) (string, error) {
+	const q = `INSERT INTO books (id, title, author, quantity)
+				VALUES ($1, $2, $3, $4);`
+	ctx, span := s.startSpan(ctx, "INSERT", q)
+	defer span.End()
+
+	_, err := s.exec(ctx, q, b.ID, b.Title, b.Author, b.Quantity)
+	if isUniqueViolation(err) {
+		return b.ID, book.ErrDuplicate
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return b.ID, nil
+}
+
+// Update replaces an existing book.
+func (s *Postgres) Update(ctx context.Context, b book.Book) error {
+	const q = `UPDATE books
+				SET title=$1, author=$2, quantity=$3
+				WHERE id=$4;`
+	ctx, span := s.startSpan(ctx, "UPDATE", q)
+	defer span.End()
+
+	tag, err := s.exec(ctx, q, b.Title, b.Author, b.Quantity, b.ID)
+	if isUniqueViolation(err) {
+		return book.ErrDuplicate
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return book.ErrNotFound
+	}
+
+	return nil
+}
+
+// Modify locks the row with SELECT ... FOR UPDATE until fn's changes are
+// written, so concurrent checkouts of the last copy can't both succeed. Within
+// a WithTx fn it runs under a savepoint, so a failure leaves the transaction usable.
+func (s *Postgres) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	const (
+		sel = `SELECT id, title, author, quantity FROM books WHERE id=$1 FOR UPDATE;`
+		upd = `UPDATE books SET title=$1, author=$2, quantity=$3 WHERE id=$4;`
+	)
+	ctx, span := s.startSpan(ctx, "SELECT", sel)
+	defer span.End()
+
+	var found, b book.Book
+	err := s.atomic(ctx, func(tx pgx.Tx) (err error) {
+		if found, err = scanBook(tx.QueryRow(ctx, sel, id)); err != nil {
+			return err
+		}
+
+		b = found
+		if err := fn(&b); err != nil {
+			return err
+		}
+		b.ID = found.ID
+
+		_, err = tx.Exec(ctx, upd, b.Title, b.Author, b.Quantity, b.ID)
+		if isUniqueViolation(err) {
+			return book.ErrDuplicate
+		}
+
+		return err
+	})
+	if err != nil {
+		return found, err
+	}
+
+	return b, nil
+}
+
+// FindByTitle returns the books whose title contains title, case-insensitively.
+func (s *Postgres) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM books
+				WHERE title ILIKE $1
+				ORDER BY lower(title), id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q, containsPattern(title))
+}
+
+// FindByAuthor returns the books whose author contains author, case-insensitively.
+func (s *Postgres) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM books
+				WHERE author ILIKE $1
+				ORDER BY lower(author), id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q, containsPattern(author))
+}
+
+// query runs a SELECT of books.
+func (s *Postgres) query(ctx context.Context, q string, args ...any) ([]book.Book, error) {
+	rows, err := s.q().Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (book.Book, error) {
+		var b book.Book
+		err := row.Scan(&b.ID, &b.Title, &b.Author, &b.Quantity)
+		return b, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil {
+		out = []book.Book{}
+	}
+
+	return out, nil
+}
+
+// scanBook reads a single book, ErrNotFound when there is no row.
+func scanBook(row pgx.Row) (book.Book, error) {
+	var b book.Book
+	err := row.Scan(&b.ID, &b.Title, &b.Author, &b.Quantity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return book.Book{}, book.ErrNotFound
+	}
+
+	if err != nil {
+		return book.Book{}, err
+	}
+
+	return b, nil
+}
+
+// containsPattern matches s anywhere, with the LIKE wildcards in s escaped so
+// searching for "100%" doesn't match everything starting with "100".
+func containsPattern(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + escaped + "%"
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// WithTx runs fn within a database transaction.
+func (s *Postgres) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		return fn(&Postgres{Pool: s.Pool, tx: tx, tracer: s.tracer})
+	})
+}
+
+// inTx runs fn within the current transaction, or else within a new one,
+// committed if fn succeeds and rolled back otherwise.
+func (s *Postgres) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	return pgx.BeginFunc(ctx, s.Pool, fn)
+}
+
+// atomic runs fn in a transaction of its own, or under a savepoint of the
+// current one: Postgres aborts a transaction on any error, while the other
+// stores let a WithTx fn carry on after, say, ErrDuplicate.
+func (s *Postgres) atomic(ctx context.Context, fn func(pgx.Tx) error) error {
+	if s.tx != nil {
+		return pgx.BeginFunc(ctx, s.tx, fn)
+	}
+
+	return pgx.BeginFunc(ctx, s.Pool, fn)
+}
+
+// exec runs a statement that may fail on a constraint, under a savepoint
+// within a transaction (see atomic).
+func (s *Postgres) exec(ctx context.Context, q string, args ...any) (pgconn.CommandTag, error) {
+	if s.tx == nil {
+		return s.Pool.Exec(ctx, q, args...)
+	}
+
+	var tag pgconn.CommandTag
+	err := s.atomic(ctx, func(sp pgx.Tx) (err error) {
+		tag, err = sp.Exec(ctx, q, args...)
+		return err
+	})
+
+	return tag, err
+}
+
+// q runs the statements within the transaction, if there is one.
+func (s *Postgres) q() pgQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.Pool
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *Postgres) Check(ctx context.Context) error {
+	return s.Pool.Ping(ctx)
+}
+
+// Close closes the connection pool. The store of a WithTx fn leaves it open.
+func (s *Postgres) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+
+	s.Pool.Close()
+	return nil
+}
@@ -0,0 +1,116 @@
+package stores_test
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestPostgres runs the conformance suite against the server at
+// BOOK_POSTGRES_TEST_DSN (a postgres:// URL), or else an embedded Postgres,
+// whose binaries are downloaded on first use and cached in ~/.embedded-postgres-go,
+// or taken from BOOK_POSTGRES_BINARIES (a directory with bin/pg_ctl) without
+// downloading anything. Postgres is the production backend: when it is expected
+// (BOOK_POSTGRES_REQUIRED or CI set), the test fails rather than skips without it.
+func TestPostgres(t *testing.T) {
+	required := os.Getenv("BOOK_POSTGRES_REQUIRED") != "" || os.Getenv("CI") != ""
+	if testing.Short() && !required {
+		t.Skip("starts a Postgres server")
+	}
+
+	dsn := os.Getenv("BOOK_POSTGRES_TEST_DSN")
+	if dsn == "" {
+		dsn = startEmbeddedPostgres(t, required)
+	}
+
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	var databases atomic.Int32
+	storetest.Run(t, func(t *testing.T) book.Store {
+		// every subtest gets an empty database of its own
+		name := fmt.Sprintf("books_test_%d_%d", os.Getpid(), databases.Add(1))
+		if _, err := admin.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.Exec(ctx, "DROP DATABASE "+name+" WITH (FORCE)"); err != nil {
+				t.Errorf("DROP DATABASE %s: %v", name, err)
+			}
+		})
+
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Path = "/" + name
+
+		s, err := stores.NewPostgres(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := s.Migrator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
+
+// startEmbeddedPostgres starts a throwaway server on a free port and returns its
+// DSN. When it can't start, the test is skipped unless Postgres is required.
+func startEmbeddedPostgres(t *testing.T, required bool) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint32(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+
+	dir := t.TempDir()
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(dir + "/runtime").
+		DataPath(dir + "/data").
+		Logger(io.Discard)
+
+	if binaries := os.Getenv("BOOK_POSTGRES_BINARIES"); binaries != "" {
+		cfg = cfg.BinariesPath(binaries)
+	}
+
+	pg := embeddedpostgres.NewDatabase(cfg)
+	if err := pg.Start(); err != nil {
+		if required {
+			t.Fatalf("embedded Postgres: Start: %v", err)
+		}
+		t.Skipf("SKIPPING the Postgres store: embedded Postgres unavailable (%v); set BOOK_POSTGRES_TEST_DSN or BOOK_POSTGRES_BINARIES, and BOOK_POSTGRES_REQUIRED to fail instead", err)
+	}
+	t.Cleanup(func() {
+		if err := pg.Stop(); err != nil {
+			t.Errorf("embedded Postgres: Stop: %v", err)
+		}
+	})
+
+	return cfg.GetConnectionURL() + "?sslmode=disable"
+}
@@ -0,0 +1,271 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/migrate"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite" // pure Go, no cgo
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite keeps the books in a local database file, for persistence without a
+// database server.
+type SQLite struct {
+	DB     *sql.DB
+	tx     *sql.Tx // set on the store handed to a WithTx fn
+	tracer trace.Tracer
+}
+
+// NewSQLite opens (or creates) the database at path. Its schema comes from Migrator.
+func NewSQLite(path string) (*SQLite, error) {
+	if err := ensureDir(path); err != nil {
+		return nil, fmt.Errorf("ensureDir: %w", err)
+	}
+
+	// WAL makes commits cheaper; busy_timeout waits for a lock held by another process
+	// (e.g. the sqlite3 shell) instead of failing right away
+	dsn := "file:" + path + "?" + url.Values{"_pragma": {"journal_mode(WAL)", "busy_timeout(5000)", "foreign_keys(1)"}}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection keeps writes from racing for the lock
+	db.SetMaxOpenConns(1)
+
+	s := &SQLite{
+		DB:     db,
+		tracer: otel.Tracer("example/go-gin-library-api/internal/book/stores/sqlite"),
+	}
+
+	return s, nil
+}
+
+// Migrator applies the schema migrations of migrations/sqlite.
+func (s *SQLite) Migrator() (*migrate.Migrator, error) {
+	return migrate.New(s.DB, migrate.SQLite, migrationsOf("sqlite"))
+}
+
+// startSpan opens a span for a single statement, carrying the SQL text as attribute.
+func (s *SQLite) startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "sqlite."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNameSQLite,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	))
+}
+
+// List offers reading for all the current stored books.
+func (s *SQLite) List(ctx context.Context) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM Books
+				ORDER BY author COLLATE NOCASE, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q)
+}
+
+// FindById queries a book by its id.
+func (s *SQLite) FindById(ctx context.Context, id string) (book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM Books WHERE id=?;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	var b book.Book
+	err := s.q().QueryRowContext(ctx, q, id).Scan(&b.ID, &b.Title, &b.Author, &b.Quantity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return book.Book{}, book.ErrNotFound
+	}
+
+	if err != nil {
+		return book.Book{}, err
+	}
+
+	return b, nil
+}
+
+// Create writes a new book, unless one with the same id, or title and author, exists.
+// Both are unique indexes, so concurrent creates can't both pass.
+func (s *SQLite) Create(ctx context.Context, b book.Book) (string, error) {
+	const q = `INSERT INTO Books (id, title, author, quantity)
+				VALUES (?, ?, ?, ?);`
+	ctx, span := s.startSpan(ctx, "INSERT", q)
+	defer span.End()
+
+	_, err := s.q().ExecContext(ctx, q, b.ID, b.Title, b.Author, b.Quantity)
+	if isConstraintViolation(err) {
+		return b.ID, book.ErrDuplicate
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return b.ID, nil
+}
+
+// Update replaces an existing book.
+func (s *SQLite) Update(ctx context.Context, b book.Book) error {
+	const q = `UPDATE Books
+				SET title=?, author=?, quantity=?
+				WHERE id=?;`
+	ctx, span := s.startSpan(ctx, "UPDATE", q)
+	defer span.End()
+
+	res, err := s.q().ExecContext(ctx, q, b.Title, b.Author, b.Quantity, b.ID)
+	if isConstraintViolation(err) {
+		return book.ErrDuplicate
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// SQLite counts matched rows, even when the values didn't change
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return book.ErrNotFound
+	}
+
+	return nil
+}
+
+// Modify runs the read, fn and the write in a single transaction. The store has
+// one connection, so no other statement runs in between.
+func (s *SQLite) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	const (
+		sel = `SELECT id, title, author, quantity FROM Books WHERE id=?;`
+		upd = `UPDATE Books SET title=?, author=?, quantity=? WHERE id=?;`
+	)
+	ctx, span := s.startSpan(ctx, "SELECT", sel)
+	defer span.End()
+
+	var found, b book.Book
+	err := inTx(ctx, s.DB, s.tx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, sel, id).Scan(&found.ID, &found.Title, &found.Author, &found.Quantity)
+		if errors.Is(err, sql.ErrNoRows) {
+			return book.ErrNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		b = found
+		if err := fn(&b); err != nil {
+			return err
+		}
+		b.ID = found.ID
+
+		_, err = tx.ExecContext(ctx, upd, b.Title, b.Author, b.Quantity, b.ID)
+		if isConstraintViolation(err) {
+			return book.ErrDuplicate
+		}
+
+		return err
+	})
+	if err != nil {
+		return found, err
+	}
+
+	return b, nil
+}
+
+// FindByTitle returns the books whose title contains title, case-insensitively.
+func (s *SQLite) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM Books
+				WHERE title LIKE ? ESCAPE '\'
+				ORDER BY title COLLATE NOCASE, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q, containsPattern(title))
+}
+
+// FindByAuthor returns the books whose author contains author, case-insensitively.
+func (s *SQLite) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	const q = `SELECT id, title, author, quantity FROM Books
+				WHERE author LIKE ? ESCAPE '\'
+				ORDER BY author COLLATE NOCASE, id;`
+	ctx, span := s.startSpan(ctx, "SELECT", q)
+	defer span.End()
+
+	return s.query(ctx, q, containsPattern(author))
+}
+
+// isConstraintViolation reports whether err is a primary key or unique index violation.
+func isConstraintViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || code == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// query runs a SELECT of books.
+func (s *SQLite) query(ctx context.Context, q string, args ...any) ([]book.Book, error) {
+	rows, err := s.q().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []book.Book{}
+	for rows.Next() {
+		var b book.Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Quantity); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// WithTx runs fn within a database transaction.
+func (s *SQLite) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	return inTx(ctx, s.DB, s.tx, func(tx *sql.Tx) error {
+		return fn(&SQLite{DB: s.DB, tx: tx, tracer: s.tracer})
+	})
+}
+
+// q runs the statements within the transaction, if there is one.
+func (s *SQLite) q() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.DB
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *SQLite) Check(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// Close closes the database. The store of a WithTx fn leaves it open.
+func (s *SQLite) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+
+	return s.DB.Close()
+}
@@ -0,0 +1,30 @@
+package stores_test
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/book/storetest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) book.Store {
+		s, err := stores.NewSQLite(filepath.Join(t.TempDir(), "books.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := s.Migrator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
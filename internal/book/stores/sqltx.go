@@ -0,0 +1,34 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier is what *sql.DB and *sql.Tx have in common, so a store runs the same
+// statements in and out of a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// inTx runs fn within tx when there is one already, or else within a new
+// transaction of db, committed if fn succeeds and rolled back otherwise.
+func inTx(ctx context.Context, db *sql.DB, tx *sql.Tx, fn func(*sql.Tx) error) error {
+	if tx != nil {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
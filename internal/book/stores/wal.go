@@ -0,0 +1,165 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/go-gin-library-api/internal/book"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrCorruptLog is returned when a record in the middle of the log is damaged,
+// which, unlike a torn last record, a crash can't explain.
+var ErrCorruptLog = fmt.Errorf("corrupt write-ahead log")
+
+// walRecord is one line of the log: the books a single write put, all or nothing.
+type walRecord struct {
+	Put []book.Book `json:"put"`
+}
+
+// appendRecord writes the books to the end of the log as one line, prefixed
+// with the CRC-32 of its JSON, and syncs it to disk. A failed write is cut off
+// again so the next record doesn't follow a partial one.
+func appendRecord(f *os.File, books []book.Book) error {
+	payload, err := json.Marshal(walRecord{Put: books})
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(line); err != nil {
+		f.Truncate(info.Size())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Truncate(info.Size())
+		return err
+	}
+
+	return nil
+}
+
+// replayLog applies the records of the log at f to data and returns how many
+// there were. A torn or damaged last record, left by a crash halfway through
+// appending it, was never acknowledged, so it is cut off and the rest kept.
+func replayLog(f *os.File, data map[string]book.Book) (int, error) {
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var offset, records int
+	for offset < len(content) {
+		line := content[offset:]
+		end := bytes.IndexByte(line, '\n')
+		if end >= 0 {
+			line = line[:end]
+		}
+
+		rec, err := parseRecord(line)
+		if err != nil || end < 0 {
+			if end >= 0 && offset+end+1 < len(content) {
+				return records, fmt.Errorf("%w: %s at byte %d: %v", ErrCorruptLog, f.Name(), offset, err)
+			}
+
+			log.Printf("Discarding incomplete last record of %s at byte %d", f.Name(), offset)
+			if err := f.Truncate(int64(offset)); err != nil {
+				return records, err
+			}
+			if err := f.Sync(); err != nil {
+				return records, err
+			}
+			break
+		}
+
+		for _, b := range rec.Put {
+			data[b.ID] = b
+		}
+		records++
+		offset += end + 1
+	}
+
+	_, err = f.Seek(0, io.SeekEnd)
+	return records, err
+}
+
+// parseRecord checks the CRC of a log line and decodes it.
+func parseRecord(line []byte) (walRecord, error) {
+	var rec walRecord
+
+	sum, payload, ok := bytes.Cut(line, []byte(" "))
+	if !ok || len(sum) != 8 {
+		return rec, fmt.Errorf("malformed record")
+	}
+
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil {
+		return rec, fmt.Errorf("malformed checksum")
+	}
+
+	if crc32.ChecksumIEEE(payload) != uint32(want) {
+		return rec, fmt.Errorf("checksum mismatch")
+	}
+
+	return rec, json.Unmarshal(payload, &rec)
+}
+
+// writeSnapshot replaces the file at path with data: it is written to a .tmp,
+// synced, renamed over path and the directory synced, so after a crash path
+// holds either the old or the new books, never a mix.
+func writeSnapshot(path string, data map[string]book.Book) error {
+	tmp := path + ".tmp"
+
+	bytes, err := json.MarshalIndent(data, "", " ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a file created or renamed in dir survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,221 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/book"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crash leaves j the way a killed process would: the log is closed without
+// being compacted into the file.
+func crash(t *testing.T, j *JSON) {
+	t.Helper()
+
+	if err := j.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createBooks creates n books, one log record each.
+func createBooks(t *testing.T, j *JSON, n int) {
+	t.Helper()
+
+	for i := range n {
+		b := book.Book{ID: fmt.Sprintf("b%d", i), Title: fmt.Sprintf("Title %d", i), Author: "Author", Quantity: 1}
+		if _, err := j.Create(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return info.Size()
+}
+
+func TestJSONReplaysLogAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+	ctx := context.Background()
+
+	j, err := NewJSON(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createBooks(t, j, 2)
+
+	err = j.WithTx(ctx, func(tx book.Store) error {
+		if _, err := book.Modify(ctx, tx, "b0", func(b *book.Book) error { b.Quantity--; return nil }); err != nil {
+			return err
+		}
+		_, err := book.Modify(ctx, tx, "b1", func(b *book.Book) error { b.Quantity++; return nil })
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash(t, j)
+
+	j, err = NewJSON(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	// two creates and the transaction as a single record
+	if j.records != 3 {
+		t.Errorf("replayed %d records, want 3", j.records)
+	}
+
+	for id, want := range map[string]int{"b0": 0, "b1": 2} {
+		b, err := j.FindById(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Quantity != want {
+			t.Errorf("%s has %d copies after replay, want %d", id, b.Quantity, want)
+		}
+	}
+}
+
+func TestJSONDiscardsTornLastRecord(t *testing.T) {
+	tests := map[string]string{
+		"unterminated":      `1234abcd {"put":[{"id":"torn"`,
+		"checksum mismatch": "1234abcd {\"put\":[]}\n",
+		"malformed":         "not a record\n",
+	}
+
+	for name, torn := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "books.json")
+
+			j, err := NewJSON(path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			createBooks(t, j, 2)
+			crash(t, j)
+
+			intact := walSize(t, path)
+
+			f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString(torn); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			j, err = NewJSON(path, nil)
+			if err != nil {
+				t.Fatalf("reopening with a torn last record: %v", err)
+			}
+			defer j.Close()
+
+			if got := walSize(t, path); got != intact {
+				t.Errorf("log is %d bytes after recovery, want the %d before the torn record", got, intact)
+			}
+
+			books, err := j.List(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(books) != 2 {
+				t.Errorf("recovered %d books, want 2", len(books))
+			}
+
+			// the next record follows the intact ones
+			if _, err := j.Create(context.Background(), book.Book{ID: "next", Title: "Next", Author: "Author"}); err != nil {
+				t.Fatal(err)
+			}
+			if j.records != 3 {
+				t.Errorf("%d records after writing on, want 3", j.records)
+			}
+		})
+	}
+}
+
+func TestJSONCorruptMiddleRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	j, err := NewJSON(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createBooks(t, j, 3)
+	crash(t, j)
+
+	content, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flip a byte of the second record's JSON, which its checksum no longer matches
+	second := bytes.IndexByte(content, '\n') + 1
+	content[second+12] ^= 0x20
+	if err := os.WriteFile(path+".wal", content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewJSON(path, nil); !errors.Is(err, ErrCorruptLog) {
+		t.Fatalf("NewJSON = %v, want %v", err, ErrCorruptLog)
+	}
+
+	// the damaged log is left alone for someone to look at
+	after, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, content) {
+		t.Error("the corrupt log was modified")
+	}
+}
+
+func TestJSONCompactsAtCompactEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+
+	j, err := NewJSON(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createBooks(t, j, compactEvery-1)
+	if j.records != compactEvery-1 || walSize(t, path) == 0 {
+		t.Fatalf("compacted before %d records: %d in a log of %d bytes", compactEvery, j.records, walSize(t, path))
+	}
+
+	// the compactEvery-th record folds the log into the file
+	if _, err := j.Create(context.Background(), book.Book{ID: "last", Title: "Last", Author: "Author"}); err != nil {
+		t.Fatal(err)
+	}
+	if j.records != 0 || walSize(t, path) != 0 {
+		t.Fatalf("not compacted at %d records: %d in a log of %d bytes", compactEvery, j.records, walSize(t, path))
+	}
+	crash(t, j)
+
+	// the file alone has every book
+	j, err = NewJSON(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	books, err := j.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != compactEvery {
+		t.Errorf("%d books after compaction, want %d", len(books), compactEvery)
+	}
+}
@@ -0,0 +1,679 @@
+// Package storetest checks that a book.Store honours the contract the service
+// and the handlers rely on, so every backend behaves the same. Run it from the
+// tests of a backend:
+//
+//	func TestMemory(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) book.Store {
+//			s, err := stores.NewMemory(nil)
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return s
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/book"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Factory returns a new, empty store. Run closes it at the end of each subtest.
+type Factory func(t *testing.T) book.Store
+
+// Run checks every method of the stores returned by newStore:
+//
+//   - Create returns the id of the book, also alongside ErrDuplicate when a book
+//     with the same id, or the same title and author ignoring case, exists.
+//   - FindById and Update return ErrNotFound for an unknown id, and Update
+//     returns ErrDuplicate when it would give a book the title and author of another.
+//   - List and FindByAuthor are ordered by author, FindByTitle by title, ignoring
+//     case; they return an empty slice, not nil, when nothing matches.
+//   - FindByTitle and FindByAuthor match a substring ignoring case, and take
+//     LIKE wildcards (% and _) literally.
+//   - book.FindByTitlePrefix and book.FindByAuthorPrefix on the store only
+//     match from the start of the value, ignoring case, in the same order.
+//   - book.Modify on the store is atomic: concurrent checkouts never lend
+//     more copies than there are.
+//   - WithTx keeps every write of fn when it succeeds and none when it fails,
+//     including those of a nested WithTx. A write failing with ErrDuplicate or
+//     ErrNotFound doesn't spoil the transaction, fn may carry on.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, book.Store)
+	}{
+		{"CreateAndFindById", testCreateAndFindById},
+		{"CreateDuplicateID", testCreateDuplicateID},
+		{"CreateDuplicateTitleAuthor", testCreateDuplicateTitleAuthor},
+		{"FindByIdNotFound", testFindByIdNotFound},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"UpdateDuplicate", testUpdateDuplicate},
+		{"List", testList},
+		{"ListEmpty", testListEmpty},
+		{"FindByTitle", testFindByTitle},
+		{"FindByAuthor", testFindByAuthor},
+		{"FindWildcards", testFindWildcards},
+		{"FindByPrefix", testFindByPrefix},
+		{"Modify", testModify},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentCreateDuplicate", testConcurrentCreateDuplicate},
+		{"ConcurrentModify", testConcurrentModify},
+		{"WithTxCommit", testWithTxCommit},
+		{"WithTxRollback", testWithTxRollback},
+		{"WithTxNested", testWithTxNested},
+		{"WithTxAfterDuplicate", testWithTxAfterDuplicate},
+		{"ConcurrentWithTx", testConcurrentWithTx},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(t)
+			t.Cleanup(func() {
+				if err := store.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			})
+
+			tt.fn(t, store)
+		})
+	}
+}
+
+var (
+	gatsby  = book.Book{ID: "b1", Title: "The Great Gatsby", Author: "F. Scott Fitzgerald", Quantity: 5}
+	war     = book.Book{ID: "b2", Title: "War and Peace", Author: "Leo Tolstoy", Quantity: 6}
+	anna    = book.Book{ID: "b3", Title: "Anna Karenina", Author: "Leo Tolstoy", Quantity: 4}
+	proust  = book.Book{ID: "b4", Title: "In Search of Lost Time", Author: "Marcel Proust", Quantity: 2}
+	library = []book.Book{gatsby, war, anna, proust}
+)
+
+// create stores books, failing the test on any error.
+func create(t *testing.T, store book.Store, books ...book.Book) {
+	t.Helper()
+
+	for _, b := range books {
+		id, err := store.Create(context.Background(), b)
+		if err != nil {
+			t.Fatalf("Create(%s): %v", b.ID, err)
+		}
+		if id != b.ID {
+			t.Fatalf("Create(%s) returned id %q", b.ID, id)
+		}
+	}
+}
+
+// find fails the test unless id is stored as want.
+func find(t *testing.T, store book.Store, want book.Book) {
+	t.Helper()
+
+	got, err := store.FindById(context.Background(), want.ID)
+	if err != nil {
+		t.Fatalf("FindById(%s): %v", want.ID, err)
+	}
+	if got != want {
+		t.Fatalf("FindById(%s) = %+v, want %+v", want.ID, got, want)
+	}
+}
+
+// ids returns the ids of books, in order.
+func ids(books []book.Book) []string {
+	out := make([]string, len(books))
+	for i, b := range books {
+		out[i] = b.ID
+	}
+	return out
+}
+
+// sameIDs fails the test unless got has exactly the ids of want, in any order.
+func sameIDs(t *testing.T, call string, got []book.Book, want ...book.Book) {
+	t.Helper()
+
+	gotIDs, wantIDs := ids(got), ids(want)
+	slices.Sort(gotIDs)
+	slices.Sort(wantIDs)
+	if !slices.Equal(gotIDs, wantIDs) {
+		t.Fatalf("%s returned %v, want %v", call, gotIDs, wantIDs)
+	}
+}
+
+// sorted fails the test unless books are ordered by key, ignoring case.
+func sorted(t *testing.T, call string, books []book.Book, key func(book.Book) string) {
+	t.Helper()
+
+	for i := 1; i < len(books); i++ {
+		if strings.ToLower(key(books[i-1])) > strings.ToLower(key(books[i])) {
+			t.Fatalf("%s isn't ordered: %q before %q", call, key(books[i-1]), key(books[i]))
+		}
+	}
+}
+
+func byTitle(b book.Book) string  { return b.Title }
+func byAuthor(b book.Book) string { return b.Author }
+
+func testCreateAndFindById(t *testing.T, store book.Store) {
+	create(t, store, library...)
+
+	for _, b := range library {
+		find(t, store, b)
+	}
+}
+
+func testCreateDuplicateID(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+
+	other := book.Book{ID: gatsby.ID, Title: "Other", Author: "Someone", Quantity: 1}
+	id, err := store.Create(context.Background(), other)
+	if !errors.Is(err, book.ErrDuplicate) {
+		t.Fatalf("Create with a taken id: err = %v, want ErrDuplicate", err)
+	}
+	if id != other.ID {
+		t.Fatalf("Create with a taken id returned id %q, want %q", id, other.ID)
+	}
+
+	find(t, store, gatsby)
+}
+
+func testCreateDuplicateTitleAuthor(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+
+	dup := book.Book{ID: "dup", Title: strings.ToUpper(gatsby.Title), Author: strings.ToLower(gatsby.Author), Quantity: 1}
+	id, err := store.Create(context.Background(), dup)
+	if !errors.Is(err, book.ErrDuplicate) {
+		t.Fatalf("Create with a taken title and author: err = %v, want ErrDuplicate", err)
+	}
+	if id != dup.ID {
+		t.Fatalf("Create with a taken title and author returned id %q, want %q", id, dup.ID)
+	}
+
+	if _, err := store.FindById(context.Background(), dup.ID); !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("duplicate was stored: FindById err = %v", err)
+	}
+
+	// the same title by another author is another book
+	create(t, store, book.Book{ID: "other", Title: gatsby.Title, Author: "Someone Else", Quantity: 1})
+}
+
+func testFindByIdNotFound(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+
+	got, err := store.FindById(context.Background(), "missing")
+	if !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("FindById(missing): err = %v, want ErrNotFound", err)
+	}
+	if got != (book.Book{}) {
+		t.Fatalf("FindById(missing) = %+v, want the zero Book", got)
+	}
+}
+
+func testUpdate(t *testing.T, store book.Store) {
+	create(t, store, gatsby, war)
+
+	changed := gatsby
+	changed.Quantity = 0
+	if err := store.Update(context.Background(), changed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	find(t, store, changed)
+
+	renamed := changed
+	renamed.Title, renamed.Author = "Tender Is the Night", "F. Scott Fitzgerald"
+	if err := store.Update(context.Background(), renamed); err != nil {
+		t.Fatalf("Update with a new title: %v", err)
+	}
+	find(t, store, renamed)
+	find(t, store, war)
+
+	// the old title and author are free again
+	create(t, store, book.Book{ID: "again", Title: gatsby.Title, Author: gatsby.Author, Quantity: 1})
+}
+
+func testUpdateNotFound(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+
+	err := store.Update(context.Background(), book.Book{ID: "missing", Title: "Missing", Author: "Nobody", Quantity: 1})
+	if !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("Update(missing): err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := store.FindById(context.Background(), "missing"); !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("Update(missing) created the book: FindById err = %v", err)
+	}
+}
+
+func testUpdateDuplicate(t *testing.T, store book.Store) {
+	create(t, store, gatsby, war)
+
+	clash := war
+	clash.Title, clash.Author = strings.ToLower(gatsby.Title), gatsby.Author
+	if err := store.Update(context.Background(), clash); !errors.Is(err, book.ErrDuplicate) {
+		t.Fatalf("Update to a taken title and author: err = %v, want ErrDuplicate", err)
+	}
+
+	find(t, store, war)
+}
+
+func testList(t *testing.T, store book.Store) {
+	create(t, store, library...)
+
+	got, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	sameIDs(t, "List", got, library...)
+	sorted(t, "List", got, byAuthor)
+}
+
+func testListEmpty(t *testing.T, store book.Store) {
+	got, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("List of an empty store = %#v, want an empty slice", got)
+	}
+}
+
+func testFindByTitle(t *testing.T, store book.Store) {
+	create(t, store, library...)
+	ctx := context.Background()
+
+	got, err := store.FindByTitle(ctx, "AN")
+	if err != nil {
+		t.Fatalf("FindByTitle: %v", err)
+	}
+	sameIDs(t, "FindByTitle(AN)", got, war, anna)
+	sorted(t, "FindByTitle(AN)", got, byTitle)
+
+	got, err = store.FindByTitle(ctx, "great gatsby")
+	if err != nil {
+		t.Fatalf("FindByTitle: %v", err)
+	}
+	sameIDs(t, "FindByTitle(great gatsby)", got, gatsby)
+
+	got, err = store.FindByTitle(ctx, "no such title")
+	if err != nil {
+		t.Fatalf("FindByTitle: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("FindByTitle without a match = %#v, want an empty slice", got)
+	}
+}
+
+func testFindByAuthor(t *testing.T, store book.Store) {
+	create(t, store, library...)
+	ctx := context.Background()
+
+	got, err := store.FindByAuthor(ctx, "tolstoy")
+	if err != nil {
+		t.Fatalf("FindByAuthor: %v", err)
+	}
+	sameIDs(t, "FindByAuthor(tolstoy)", got, war, anna)
+
+	got, err = store.FindByAuthor(ctx, "R")
+	if err != nil {
+		t.Fatalf("FindByAuthor: %v", err)
+	}
+	sameIDs(t, "FindByAuthor(R)", got, gatsby, proust)
+	sorted(t, "FindByAuthor(R)", got, byAuthor)
+
+	got, err = store.FindByAuthor(ctx, "no such author")
+	if err != nil {
+		t.Fatalf("FindByAuthor: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("FindByAuthor without a match = %#v, want an empty slice", got)
+	}
+}
+
+func testFindByPrefix(t *testing.T, store book.Store) {
+	create(t, store, library...)
+	ctx := context.Background()
+
+	got, err := book.FindByTitlePrefix(ctx, store, "AN")
+	if err != nil {
+		t.Fatalf("FindByTitlePrefix: %v", err)
+	}
+	sameIDs(t, "FindByTitlePrefix(AN)", got, anna)
+
+	got, err = book.FindByTitlePrefix(ctx, store, "the g")
+	if err != nil {
+		t.Fatalf("FindByTitlePrefix: %v", err)
+	}
+	sameIDs(t, "FindByTitlePrefix(the g)", got, gatsby)
+
+	got, err = book.FindByAuthorPrefix(ctx, store, "leo")
+	if err != nil {
+		t.Fatalf("FindByAuthorPrefix: %v", err)
+	}
+	sameIDs(t, "FindByAuthorPrefix(leo)", got, war, anna)
+	sorted(t, "FindByAuthorPrefix(leo)", got, byAuthor)
+
+	got, err = book.FindByAuthorPrefix(ctx, store, "tolstoy")
+	if err != nil {
+		t.Fatalf("FindByAuthorPrefix: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("FindByAuthorPrefix(tolstoy) = %#v, want an empty slice", got)
+	}
+}
+
+func testFindWildcards(t *testing.T, store book.Store) {
+	pure := book.Book{ID: "pure", Title: "100% Pure", Author: "A_Author", Quantity: 1}
+	create(t, store, gatsby, pure)
+	ctx := context.Background()
+
+	got, err := store.FindByTitle(ctx, "0% p")
+	if err != nil {
+		t.Fatalf("FindByTitle: %v", err)
+	}
+	sameIDs(t, "FindByTitle(0% p)", got, pure)
+
+	got, err = store.FindByTitle(ctx, "%")
+	if err != nil {
+		t.Fatalf("FindByTitle: %v", err)
+	}
+	sameIDs(t, "FindByTitle(%)", got, pure)
+
+	got, err = store.FindByAuthor(ctx, "_")
+	if err != nil {
+		t.Fatalf("FindByAuthor: %v", err)
+	}
+	sameIDs(t, "FindByAuthor(_)", got, pure)
+}
+
+func testModify(t *testing.T, store book.Store) {
+	create(t, store, proust)
+	ctx := context.Background()
+
+	got, err := book.Modify(ctx, store, proust.ID, func(b *book.Book) error {
+		b.Quantity--
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Modify: %v", err)
+	}
+
+	want := proust
+	want.Quantity--
+	if got != want {
+		t.Fatalf("Modify = %+v, want %+v", got, want)
+	}
+	find(t, store, want)
+
+	failure := fmt.Errorf("refused")
+	got, err = book.Modify(ctx, store, proust.ID, func(b *book.Book) error {
+		b.Quantity = 100
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Modify with a failing fn: err = %v, want %v", err, failure)
+	}
+	if got != want {
+		t.Fatalf("Modify with a failing fn = %+v, want the book as found %+v", got, want)
+	}
+	find(t, store, want)
+
+	if _, err := book.Modify(ctx, store, "missing", func(*book.Book) error { return nil }); !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("Modify(missing): err = %v, want ErrNotFound", err)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, store book.Store) {
+	const n = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Go(func() {
+			b := book.Book{ID: fmt.Sprintf("c%02d", i), Title: fmt.Sprintf("Title %02d", i), Author: "Author", Quantity: 1}
+			if _, err := store.Create(context.Background(), b); err != nil {
+				errs <- fmt.Errorf("Create(%s): %w", b.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	got, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != n {
+		t.Fatalf("List returned %d books after %d concurrent creates", len(got), n)
+	}
+}
+
+func testConcurrentCreateDuplicate(t *testing.T, store book.Store) {
+	const n = 10
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := range n {
+		wg.Go(func() {
+			b := book.Book{ID: fmt.Sprintf("d%02d", i), Title: "Same Title", Author: "Same Author", Quantity: 1}
+			_, err := store.Create(context.Background(), b)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, book.ErrDuplicate):
+				t.Errorf("Create(%s): %v", b.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatalf("%d of %d concurrent creates of the same book succeeded, want 1", created, n)
+	}
+}
+
+func testConcurrentModify(t *testing.T, store book.Store) {
+	const n = 20
+	create(t, store, gatsby)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		loaned int
+	)
+	for range n {
+		wg.Go(func() {
+			_, err := book.Modify(context.Background(), store, gatsby.ID, func(b *book.Book) error {
+				if b.Quantity == 0 {
+					return book.ErrBookUnavailable
+				}
+				b.Quantity--
+				return nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				loaned++
+			case !errors.Is(err, book.ErrBookUnavailable):
+				t.Errorf("Modify: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if loaned != gatsby.Quantity {
+		t.Fatalf("%d concurrent checkouts of %d copies succeeded", loaned, gatsby.Quantity)
+	}
+
+	want := gatsby
+	want.Quantity = 0
+	find(t, store, want)
+}
+
+func testWithTxCommit(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+	ctx := context.Background()
+
+	changed := gatsby
+	changed.Quantity = 1
+	err := store.WithTx(ctx, func(tx book.Store) error {
+		create(t, tx, war)
+		if err := tx.Update(ctx, changed); err != nil {
+			return err
+		}
+
+		// the transaction sees its own writes
+		find(t, tx, war)
+		find(t, tx, changed)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	find(t, store, war)
+	find(t, store, changed)
+}
+
+func testWithTxRollback(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+	ctx := context.Background()
+
+	failure := fmt.Errorf("abort")
+	err := store.WithTx(ctx, func(tx book.Store) error {
+		create(t, tx, war)
+
+		changed := gatsby
+		changed.Quantity = 0
+		if err := tx.Update(ctx, changed); err != nil {
+			return err
+		}
+
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx with a failing fn: err = %v, want %v", err, failure)
+	}
+
+	find(t, store, gatsby)
+	if _, err := store.FindById(ctx, war.ID); !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("book created in a rolled back transaction: FindById err = %v", err)
+	}
+}
+
+func testWithTxNested(t *testing.T, store book.Store) {
+	ctx := context.Background()
+
+	failure := fmt.Errorf("abort")
+	err := store.WithTx(ctx, func(tx book.Store) error {
+		err := tx.WithTx(ctx, func(inner book.Store) error {
+			create(t, inner, war)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx with a failing fn: err = %v, want %v", err, failure)
+	}
+
+	if _, err := store.FindById(ctx, war.ID); !errors.Is(err, book.ErrNotFound) {
+		t.Fatalf("book created in a nested WithTx survived the outer rollback: FindById err = %v", err)
+	}
+}
+
+func testWithTxAfterDuplicate(t *testing.T, store book.Store) {
+	create(t, store, gatsby)
+	ctx := context.Background()
+
+	clash := war
+	clash.Title, clash.Author = gatsby.Title, gatsby.Author
+	err := store.WithTx(ctx, func(tx book.Store) error {
+		if _, err := tx.Create(ctx, gatsby); !errors.Is(err, book.ErrDuplicate) {
+			t.Errorf("Create of a duplicate in WithTx: err = %v, want ErrDuplicate", err)
+		}
+
+		create(t, tx, war)
+
+		if err := tx.Update(ctx, clash); !errors.Is(err, book.ErrDuplicate) {
+			t.Errorf("Update to a duplicate in WithTx: err = %v, want ErrDuplicate", err)
+		}
+
+		if _, err := book.Modify(ctx, tx, "missing", func(*book.Book) error { return nil }); !errors.Is(err, book.ErrNotFound) {
+			t.Errorf("Modify of an unknown id in WithTx: err = %v, want ErrNotFound", err)
+		}
+
+		create(t, tx, anna)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx carrying on after ErrDuplicate: %v", err)
+	}
+
+	find(t, store, gatsby)
+	find(t, store, war)
+	find(t, store, anna)
+}
+
+func testConcurrentWithTx(t *testing.T, store book.Store) {
+	const n = 20
+	create(t, store, proust)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		loaned int
+	)
+	for range n {
+		wg.Go(func() {
+			ctx := context.Background()
+			err := store.WithTx(ctx, func(tx book.Store) error {
+				_, err := book.Modify(ctx, tx, proust.ID, func(b *book.Book) error {
+					if b.Quantity == 0 {
+						return book.ErrBookUnavailable
+					}
+					b.Quantity--
+					return nil
+				})
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				loaned++
+			case !errors.Is(err, book.ErrBookUnavailable):
+				t.Errorf("WithTx: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if loaned != proust.Quantity {
+		t.Fatalf("%d concurrent checkouts of %d copies succeeded", loaned, proust.Quantity)
+	}
+
+	want := proust
+	want.Quantity = 0
+	find(t, store, want)
+}
@@ -0,0 +1,55 @@
+package bootstrap
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/audit"
+	"example/go-gin-library-api/internal/audit/sinks"
+	"example/go-gin-library-api/internal/config"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// newAuditLog opens the configured audit sink and resumes its hash chain.
+func newAuditLog(cfg config.Audit) (*audit.Log, error) {
+	log.Printf("Writing the audit trail to %q", cfg.Sink)
+
+	var (
+		sink audit.Sink
+		err  error
+	)
+	switch strings.ToLower(cfg.Sink) {
+	case "file":
+		sink, err = sinks.NewFile(cfg.Path)
+	case "mysql":
+		sink, err = newMySQLSink(cfg.MySQLDSN)
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.Sink)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := audit.New(context.Background(), sink)
+	if err != nil {
+		sink.Close()
+		return nil, fmt.Errorf("audit.New: %w", err)
+	}
+
+	return l, nil
+}
+
+// newMySQLSink opens the MySQL sink, creating its table if needed.
+func newMySQLSink(dsn string) (audit.Sink, error) {
+	store, err := sinks.NewMySQL(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyMigrations("audit", store); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
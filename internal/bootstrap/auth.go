@@ -1,70 +1,157 @@
 package bootstrap
 
 import (
+	"context"
 	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/auth/clients"
+	"example/go-gin-library-api/internal/auth/oidc"
+	"example/go-gin-library-api/internal/auth/tokens"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/metrics"
+	"example/go-gin-library-api/internal/secret"
 	"fmt"
 	"log"
-	"os"
-
-	"github.com/joho/godotenv"
+	"strings"
+	"time"
 )
 
-type AuthConfig struct {
-	JWTSecret string
-	Issuer    string
-	Audience  string
-	Addr      string
-}
+// newClientRepo creates the configured client repository. The configured
+// client, if any, is seeded into it when not registered yet.
+func newClientRepo(cfg config.Auth) (auth.ClientStore, error) {
+	seed, err := seedClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Loading clients from %q", cfg.Clients.Driver)
 
-// LoadAuthConfigFromEnv reads the auth settings from .env variables, ensuring
-// required values exist before returning a populated AuthConfig.
-func LoadAuthConfigFromEnv() (AuthConfig, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return AuthConfig{}, fmt.Errorf("godotenv.Load: %w", err)
+	switch strings.ToLower(cfg.Clients.Driver) {
+	case "mysql":
+		// the seed waits for the table
+		store, err := clients.NewMySQL(cfg.Clients.MySQLDSN, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applyMigrations("clients", store); err != nil {
+			store.Close()
+			return nil, err
+		}
+
+		if err := store.Seed(context.Background(), seed); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("store.Seed: %w", err)
+		}
+
+		return store, nil
+	case "json":
+		return clients.NewJSON(cfg.Clients.JSONPath, seed)
+	case "memory":
+		return clients.NewMemory(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown client repository %q", cfg.Clients.Driver)
 	}
+}
 
-	log.Print("Loading Auth configs from env")
+// tokenStore keeps the refresh tokens, authorization codes and the access token revocation list.
+type tokenStore interface {
+	auth.RevocationList
+	auth.RefreshStore
+	auth.CodeStore
+}
+
+// newTokenStore creates the configured refresh token and revocation store.
+func newTokenStore(cfg config.Tokens) (tokenStore, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "json":
+		return tokens.NewJSON(cfg.JSONPath)
+	case "memory":
+		return tokens.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q", cfg.Driver)
+	}
+}
 
-	secret, ok := os.LookupEnv("JWT_SECRET")
-	if !ok {
-		return AuthConfig{}, fmt.Errorf("godotenv.Load: variable JWT_SECRET not found")
+// seedClients builds the configured client, hashing its plain-text secret if needed.
+func seedClients(cfg config.Auth) ([]auth.Client, error) {
+	if cfg.ClientID == "" {
+		return nil, nil
 	}
 
-	issuer, ok := os.LookupEnv("ISSUER")
-	if !ok {
-		return AuthConfig{}, fmt.Errorf("godotenv.Load: variable ISSUER not found")
+	hash := cfg.ClientSecretHash
+	if hash == "" {
+		h, err := secret.Hash(cfg.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("secret.Hash: %w", err)
+		}
+		hash = h
 	}
 
-	audience, ok := os.LookupEnv("AUDIENCE")
-	if !ok {
-		return AuthConfig{}, fmt.Errorf("godotenv.Load: variable AUDIENCE not found")
+	if err := auth.ValidateScopes(cfg.ClientScopes); err != nil {
+		return nil, fmt.Errorf("auth.ValidateScopes: %w", err)
 	}
 
-	address, ok := os.LookupEnv("ADDR")
-	if !ok {
-		return AuthConfig{}, fmt.Errorf("godotenv.Load: variable ADDR not found")
+	if err := auth.ValidateRedirectURIs(cfg.ClientRedirectURIs); err != nil {
+		return nil, fmt.Errorf("auth.ValidateRedirectURIs: %w", err)
 	}
 
-	return AuthConfig{
-		JWTSecret: secret,
-		Issuer:    issuer,
-		Audience:  audience,
-		Addr:      address,
-	}, nil
+	return []auth.Client{{
+		ID:           cfg.ClientID,
+		Name:         cfg.ClientID,
+		SecretHash:   hash,
+		CreatedAt:    time.Now().UTC(),
+		Scopes:       cfg.ClientScopes,
+		RedirectURIs: cfg.ClientRedirectURIs,
+		TLSSubject:   cfg.ClientTLSSubject,
+		TLSSANs:      cfg.ClientTLSSANs,
+	}}, nil
 }
 
-// newClientRepoFromEnv builds an in-memory client repository using credentials
-// sourced from the CLIENT_ID and CLIENT_SECRET environment variables.
-func newClientRepoFromEnv() (auth.ClientRepository, error) {
-	clientID, ok := os.LookupEnv("CLIENT_ID")
-	if !ok {
-		return nil, fmt.Errorf("godotenv.Load: variable CLIENT_ID not found")
+// newGuard builds the brute-force guard of the client authentication. Alerts are
+// logged and counted; more hooks (webhooks, paging) belong in onAlert.
+func newGuard(cfg config.BruteForce, m *metrics.Metrics) *auth.Guard {
+	onAlert := func(a auth.Alert) {
+		m.AuthAlert(a.Kind)
+
+		switch a.Kind {
+		case auth.AlertLockout:
+			log.Printf("ALERT brute force: %s locked out of client %q after %d failures, until %s", a.IP, a.ClientID, a.Failures, a.Until.Format(time.RFC3339))
+		default:
+			log.Printf("ALERT brute force: client %q failed authentication %d times", a.ClientID, a.Failures)
+		}
 	}
 
-	clientSecret, ok := os.LookupEnv("CLIENT_SECRET")
-	if !ok {
-		return nil, fmt.Errorf("godotenv.Load: variable CLIENT_SECRET not found")
+	return auth.NewGuard(auth.GuardOptions{
+		FreeAttempts:     cfg.FreeAttempts,
+		BaseDelay:        time.Duration(cfg.BaseDelay),
+		MaxDelay:         time.Duration(cfg.MaxDelay),
+		LockoutAfter:     cfg.LockoutAfter,
+		Lockout:          time.Duration(cfg.Lockout),
+		ClientAlertAfter: cfg.ClientAlertAfter,
+		OnAlert:          onAlert,
+	})
+}
+
+// newExternalVerifiers creates a verifier for each trusted external issuer.
+func newExternalVerifiers(cfg []config.ExternalIssuer) (map[string]auth.TokenVerifier, error) {
+	out := make(map[string]auth.TokenVerifier, len(cfg))
+	for _, ext := range cfg {
+		for value, scopes := range ext.Mappings {
+			if err := auth.ValidateScopes(scopes); err != nil {
+				return nil, fmt.Errorf("external issuer %s, mapping %q: %w", ext.Issuer, value, err)
+			}
+		}
+
+		out[ext.Issuer] = oidc.NewProvider(oidc.Config{
+			Issuer:      ext.Issuer,
+			Audience:    ext.Audience,
+			ScopeClaim:  ext.ScopeClaim,
+			RolesClaim:  ext.RolesClaim,
+			Mappings:    ext.Mappings,
+			JWKSRefresh: time.Duration(ext.JWKSRefresh),
+		}, nil)
+		log.Printf("Accepting tokens from external issuer %s", ext.Issuer)
 	}
 
-	return auth.NewInMemoryClientRepo(map[string]string{clientID: clientSecret}), nil
+	return out, nil
 }
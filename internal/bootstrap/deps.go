@@ -1,31 +1,166 @@
 package bootstrap
 
 import (
+	"context"
+	"crypto/tls"
+	"example/go-gin-library-api/internal/audit"
 	"example/go-gin-library-api/internal/auth"
 	"example/go-gin-library-api/internal/book"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/health"
+	"example/go-gin-library-api/internal/metrics"
+	"example/go-gin-library-api/internal/patron"
+	"example/go-gin-library-api/internal/tracing"
+	"io"
+	"log"
+	"strings"
+	"time"
 )
 
-// BuildDeps wires together the auth and book handlers by pulling stores and
-// clients from the environment and instantiating the required services.
-func BuildDeps(authConfig AuthConfig) (*auth.Handler, *book.Handler, error) {
+// Deps holds everything the router needs to serve requests.
+type Deps struct {
+	AuthHandler   *auth.Handler
+	AdminHandler  *auth.AdminHandler
+	BookHandler   *book.Handler
+	AuditHandler  *audit.Handler
+	AuditLog      *audit.Log
+	PatronHandler *patron.Handler
+	Metrics       *metrics.Metrics
+	HealthHandler *health.Handler
+	RateLimits    RateLimits
+
+	// TrustedProxies may set the client IP with X-Forwarded-For.
+	TrustedProxies []string
+
+	// TLSConfig is nil when the server runs plain HTTP.
+	TLSConfig *tls.Config
+
+	// Lifecycle releases the stores and flushes telemetry once the server stopped.
+	Lifecycle *Lifecycle
+}
+
+// BuildDeps wires together the auth and book handlers by creating the configured
+// stores and clients and instantiating the required services.
+// If anything fails, whatever was already built is shut down before returning.
+func BuildDeps(cfg config.Config) (_ *Deps, err error) {
+	lc := &Lifecycle{}
+	defer func() {
+		if err != nil {
+			lc.Shutdown(context.Background())
+		}
+	}()
+
+	m := metrics.New()
+
+	tlsConfig, err := newTLSConfig(cfg.Server.TLS, lc)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTracing, err := newTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	lc.OnShutdown("tracing", shutdownTracing)
+
 	// Create stores and repositories
-	store, err := newStoreFromEnv()
+	store, backend, err := newStore(cfg.Store)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	lc.OnShutdown("book store", func(context.Context) error { return store.Close() })
 
-	clientRepo, err := newClientRepoFromEnv()
+	clientRepo, err := newClientRepo(cfg.Auth)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if c, ok := clientRepo.(io.Closer); ok {
+		lc.OnShutdown("client repository", func(context.Context) error { return c.Close() })
 	}
 
+	auditLog, err := newAuditLog(cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
+	lc.OnShutdown("audit log", func(context.Context) error { return auditLog.Close() })
+
+	keys, rotator, err := newKeySet(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if rotator != nil {
+		rotator.Start(log.Printf)
+		lc.OnShutdown("key rotation", func(context.Context) error { rotator.Stop(); return nil })
+	}
+
+	tokenStore, err := newTokenStore(cfg.Auth.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	external, err := newExternalVerifiers(cfg.Auth.External)
+	if err != nil {
+		return nil, err
+	}
+
+	patronStore, err := newPatronStore(cfg.Patrons)
+	if err != nil {
+		return nil, err
+	}
+	lc.OnShutdown("patron store", func(context.Context) error { return patronStore.Close() })
+
 	// Create services
-	authSvc := auth.NewService(authConfig.JWTSecret, authConfig.Issuer, authConfig.Audience)
-	bookSvc := book.NewService(store)
+	authSvc := auth.NewService(keys, cfg.Auth.Issuer, cfg.Auth.Audience, auth.TokenOptions{
+		AccessTTL:   time.Duration(cfg.Auth.Tokens.AccessTTL),
+		RefreshTTL:  time.Duration(cfg.Auth.Tokens.RefreshTTL),
+		Revocations: tokenStore,
+		Refresh:     tokenStore,
+		Codes:       tokenStore,
+	})
+	patronSvc := patron.NewService(patronStore)
+	clientSvc := auth.NewClientService(clientRepo, auditLog)
+	bookSvc := audit.NewBookService(book.NewService(tracing.NewStore(m.NewStore(store, backend), backend)), auditLog)
+
+	// Register readiness checks for the dependencies that can fail after startup
+	checks := health.NewRegistry(2 * time.Second)
+	if c, ok := store.(health.Checker); ok {
+		checks.Register("store", c)
+	}
+	if c, ok := authSvc.(health.Checker); ok {
+		checks.Register("auth", c)
+	}
+	if c, ok := clientRepo.(health.Checker); ok {
+		checks.Register("clients", c)
+	}
+	if c, ok := patronStore.(health.Checker); ok {
+		checks.Register("patrons", c)
+	}
 
 	// Create handlers
-	authHandler := auth.NewHandler(clientRepo, authSvc)
-	bookHandler := book.NewHandler(bookSvc)
+	authHandler := auth.NewHandler(clientRepo, authSvc, keys, auth.HandlerOptions{
+		Issuer:        cfg.Auth.Issuer,
+		PublicURL:     strings.TrimSuffix(cfg.Server.PublicURL, "/"),
+		RefreshTokens: cfg.Auth.Tokens.RefreshTTL > 0,
+		Patrons:       patronAuthenticator{patrons: patronSvc},
+		External:      external,
+		MTLS:          tlsConfig != nil && tlsConfig.ClientCAs != nil,
+		Guard:         newGuard(cfg.Auth.BruteForce, m),
+	})
+	adminHandler := auth.NewAdminHandler(clientSvc)
+	bookHandler := book.NewHandler(m.NewService(tracing.NewService(bookSvc)))
 
-	return authHandler, bookHandler, nil
+	return &Deps{
+		AuthHandler:    authHandler,
+		AdminHandler:   adminHandler,
+		BookHandler:    bookHandler,
+		AuditHandler:   audit.NewHandler(auditLog),
+		AuditLog:       auditLog,
+		PatronHandler:  patron.NewHandler(patronSvc),
+		Metrics:        m,
+		HealthHandler:  health.NewHandler(checks),
+		RateLimits:     newRateLimits(cfg.RateLimit),
+		TrustedProxies: cfg.Server.TrustedProxies,
+		TLSConfig:      tlsConfig,
+		Lifecycle:      lc,
+	}, nil
 }
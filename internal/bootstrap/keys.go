@@ -0,0 +1,95 @@
+package bootstrap
+
+import (
+	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/config"
+	"fmt"
+	"log"
+	"time"
+)
+
+// newKeySet builds the signing keys for the configured algorithm. HS256 uses the
+// shared JWT secret; asymmetric algorithms load PEM files, or generate a key when
+// none is found. The returned rotator is nil when rotation is disabled.
+func newKeySet(cfg config.Auth) (*auth.KeySet, *auth.KeyRotator, error) {
+	keys := auth.NewKeySet()
+	signing := cfg.Signing
+
+	if signing.Algorithm == auth.AlgHS256 {
+		k, err := auth.NewSigningKey(auth.AlgHS256, []byte(cfg.JWTSecret))
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth.NewSigningKey: %w", err)
+		}
+
+		keys.Add(k, true)
+		return keys, nil, nil
+	}
+
+	overlap := time.Duration(signing.RotationOverlap)
+	var active *auth.SigningKey
+
+	if signing.KeyDir != "" {
+		loaded, err := auth.LoadPEMDir(signing.KeyDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth.LoadPEMDir: %w", err)
+		}
+
+		// each key retires once its successor has been active for the overlap
+		for i, k := range loaded {
+			if i == len(loaded)-1 {
+				active = k
+				break
+			}
+			keys.AddRetiring(k, loaded[i+1].CreatedAt.Add(overlap))
+		}
+	}
+
+	for i, path := range signing.KeyFiles {
+		k, err := auth.LoadPEMKey(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth.LoadPEMKey: %w", err)
+		}
+
+		if i == 0 {
+			// explicitly configured files win over the key directory, whose newest
+			// key still verifies the tokens it signed for the overlap
+			if active != nil {
+				keys.AddRetiring(active, time.Now().Add(overlap))
+			}
+			// activated now, so only keys rotated from here on replace it
+			k.CreatedAt = time.Now().UTC()
+			active = k
+			continue
+		}
+		keys.Add(k, false)
+	}
+
+	if active == nil {
+		k, err := auth.GenerateSigningKey(signing.Algorithm)
+		if err != nil {
+			return nil, nil, fmt.Errorf("auth.GenerateSigningKey: %w", err)
+		}
+
+		if signing.KeyDir != "" {
+			if err := auth.WritePEMKey(signing.KeyDir, k); err != nil {
+				return nil, nil, fmt.Errorf("auth.WritePEMKey: %w", err)
+			}
+		} else {
+			log.Printf("No %s key configured, using an ephemeral key: tokens won't survive a restart", signing.Algorithm)
+		}
+		active = k
+	}
+
+	if active.Algorithm != signing.Algorithm {
+		return nil, nil, fmt.Errorf("signing key %s is %s, expected %s", active.ID, active.Algorithm, signing.Algorithm)
+	}
+	keys.Add(active, true)
+	log.Printf("Signing tokens with %s key %s", active.Algorithm, active.ID)
+
+	if signing.RotationInterval <= 0 {
+		return keys, nil, nil
+	}
+
+	rotator := auth.NewKeyRotator(keys, signing.Algorithm, signing.KeyDir, time.Duration(signing.RotationInterval), overlap)
+	return keys, rotator, nil
+}
@@ -0,0 +1,49 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Lifecycle collects the shutdown hooks of every dependency built at startup.
+type Lifecycle struct {
+	mu    sync.Mutex
+	hooks []shutdownHook
+}
+
+// OnShutdown registers fn to be run on shutdown. Hooks run in reverse
+// registration order, so a dependency is released after everything built on top of it.
+func (l *Lifecycle) OnShutdown(name string, fn func(context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Shutdown runs every hook once, even if some fail, and returns the joined errors.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks
+	l.hooks = nil
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		log.Printf("Shutting down %s", h.name)
+
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
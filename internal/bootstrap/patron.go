@@ -0,0 +1,58 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/auth"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/patron"
+	"example/go-gin-library-api/internal/patron/stores"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// newPatronStore creates the configured patron store.
+func newPatronStore(cfg config.Patrons) (patron.Store, error) {
+	log.Printf("Loading patrons from %q", cfg.Driver)
+
+	switch strings.ToLower(cfg.Driver) {
+	case "mysql":
+		store, err := stores.NewMySQL(cfg.MySQLDSN)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applyMigrations("patrons", store); err != nil {
+			store.Close()
+			return nil, err
+		}
+
+		return store, nil
+	case "json":
+		return stores.NewJSON(cfg.JSONPath)
+	case "memory":
+		return stores.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown patron store %q", cfg.Driver)
+	}
+}
+
+// patronAuthenticator lets the authorization endpoint sign patrons in, without
+// the auth package depending on the patron one.
+type patronAuthenticator struct {
+	patrons patron.Service
+}
+
+func (a patronAuthenticator) Authenticate(ctx context.Context, email, password string) (auth.Identity, error) {
+	p, err := a.patrons.Authenticate(ctx, email, password)
+	if errors.Is(err, patron.ErrInvalidCredentials) {
+		return auth.Identity{}, auth.ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return auth.Identity{}, err
+	}
+
+	return auth.Identity{Subject: p.ID, Name: p.Name, Email: p.Email}, nil
+}
@@ -0,0 +1,52 @@
+package bootstrap
+
+import (
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/ratelimit"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimits are the rate limit middlewares of each route group.
+type RateLimits struct {
+	Token     gin.HandlerFunc // per IP
+	Authorize gin.HandlerFunc // per IP
+	API       gin.HandlerFunc // per client, after auth.RequireAuth
+}
+
+// newRateLimits builds the limiter of the configured backend. With the none
+// backend the middlewares let everything through.
+func newRateLimits(cfg config.RateLimit) RateLimits {
+	policy := ratelimit.Policy{
+		Routes:      map[string]ratelimit.Limit{},
+		Tiers:       map[string]map[string]ratelimit.Limit{},
+		ClientTiers: map[string]string{},
+	}
+
+	if strings.ToLower(cfg.Backend) != "none" {
+		policy.Routes["token"] = toLimit(cfg.Token)
+		policy.Routes["authorize"] = toLimit(cfg.Authorize)
+		policy.Routes["api"] = toLimit(cfg.API)
+
+		for name, tier := range cfg.Tiers {
+			policy.Tiers[name] = map[string]ratelimit.Limit{"api": toLimit(tier.API)}
+			for _, id := range tier.Clients {
+				policy.ClientTiers[id] = name
+			}
+		}
+	}
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemory(), policy)
+
+	return RateLimits{
+		Token:     limiter.ByIP("token"),
+		Authorize: limiter.ByIP("authorize"),
+		API:       limiter.ByClient("api"),
+	}
+}
+
+func toLimit(l config.Limit) ratelimit.Limit {
+	return ratelimit.Limit{Requests: l.Requests, Per: time.Duration(l.Per), Burst: l.Burst}
+}
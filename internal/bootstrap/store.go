@@ -1,15 +1,16 @@
 package bootstrap
 
 import (
+	"context"
 	"example/go-gin-library-api/internal/book"
 	"example/go-gin-library-api/internal/book/stores"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/migrate"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 )
 
 var books = []book.Book{
@@ -18,28 +19,121 @@ var books = []book.Book{
 	{ID: uuid.NewString(), Title: "War and Peace", Author: "Leo Tolstoy", Quantity: 6},
 }
 
-func newStoreFromEnv() (book.Store, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return nil, fmt.Errorf("godotenv.Load: %w", err)
-	}
-
-	env, ok := os.LookupEnv("BOOK_STORE")
-	if !ok {
-		return nil, fmt.Errorf("godotenv.Load: variable BOOK_STORE not found")
-	}
+// newStore creates the configured store and returns it together with the
+// normalized backend name.
+func newStore(cfg config.Store) (book.Store, string, error) {
+	log.Printf("Starting application with store %q", cfg.Driver)
 
-	log.Printf("Starting application with store %q", env)
-
-	switch strings.ToLower(env) {
+	backend := strings.ToLower(cfg.Driver)
+	switch backend {
 	case "mysql":
-		dsn := os.Getenv("BOOK_MYSQL_DSN")
-		return stores.NewMySQL(dsn)
+		store, err := stores.NewMySQL(cfg.MySQLDSN)
+		if err != nil {
+			return nil, "", err
+		}
+		// the MySQL books are seeded by go-gin-library-infra/db
+		return store, backend, migrateStore(store, cfg.Migrate, nil)
+	case "postgres":
+		store, err := stores.NewPostgres(cfg.PostgresDSN)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, backend, migrateStore(store, cfg.Migrate, books)
+	case "bolt":
+		store, err := stores.NewBolt(cfg.BoltPath, books)
+		return store, backend, err
+	case "sqlite":
+		store, err := stores.NewSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, backend, migrateStore(store, cfg.Migrate, books)
 	case "json":
-		path := os.Getenv("BOOK_JSON_PATH")
-		return stores.NewJSON(path, books)
+		store, err := stores.NewJSON(cfg.JSONPath, books)
+		return store, backend, err
 	case "memory":
-		return stores.NewMemory(books)
+		store, err := stores.NewMemory(books)
+		return store, backend, err
 	default:
-		return nil, fmt.Errorf("unknown BOOK_STORE %q", env)
+		return nil, "", fmt.Errorf("unknown store %q", cfg.Driver)
+	}
+}
+
+// sqlStore is a store with schema migrations.
+type sqlStore interface {
+	book.Store
+	Migrator() (*migrate.Migrator, error)
+}
+
+// migrateStore applies the pending migrations of store, unless disabled (then
+// they are left to cmd/migrate). When they created the books table, and it is
+// still empty, it is seeded. The store is closed if anything fails.
+func migrateStore(store sqlStore, enabled bool, seed []book.Book) (err error) {
+	if !enabled {
+		return nil
 	}
+
+	defer func() {
+		if err != nil {
+			store.Close()
+		}
+	}()
+
+	m, err := store.Migrator()
+	if err != nil {
+		return fmt.Errorf("store.Migrator: %w", err)
+	}
+
+	ctx := context.Background()
+	applied, err := m.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("m.Up: %w", err)
+	}
+
+	for _, mig := range applied {
+		log.Printf("Applied migration %04d_%s", mig.Version, mig.Name)
+	}
+
+	// the first migration is a baseline, it is also "applied" to databases that predate migrations
+	if len(applied) == 0 || applied[0].Version != 1 || len(seed) == 0 {
+		return nil
+	}
+
+	existing, err := store.List(ctx)
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+
+	for _, b := range seed {
+		if _, err := store.Create(ctx, b); err != nil {
+			return fmt.Errorf("store.Create: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrator is a backend, other than the book store, whose table comes from migrations.
+type migrator interface {
+	Migrator() (*migrate.Migrator, error)
+}
+
+// applyMigrations applies the pending migrations of the what backend. Those
+// are baselines, their tables hold nothing to seed.
+func applyMigrations(what string, store migrator) error {
+	m, err := store.Migrator()
+	if err != nil {
+		return fmt.Errorf("store.Migrator: %w", err)
+	}
+
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("m.Up: %w", err)
+	}
+
+	for _, mig := range applied {
+		log.Printf("Applied %s migration %04d_%s", what, mig.Version, mig.Name)
+	}
+
+	return nil
 }
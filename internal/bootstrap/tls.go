@@ -0,0 +1,39 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/tlsutil"
+	"fmt"
+	"log"
+	"time"
+)
+
+// newTLSConfig loads the server certificate and starts watching it for changes.
+// It returns nil when TLS isn't configured.
+func newTLSConfig(cfg config.TLS, lc *Lifecycle) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+
+	reloader, err := tlsutil.NewReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tlsutil.NewReloader: %w", err)
+	}
+
+	tc, err := tlsutil.NewServerConfig(tlsutil.Config{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		MinVersion:   cfg.MinVersion,
+		ClientCAFile: cfg.ClientCAFile,
+	}, reloader)
+	if err != nil {
+		return nil, fmt.Errorf("tlsutil.NewServerConfig: %w", err)
+	}
+
+	reloader.Watch(time.Duration(cfg.ReloadInterval), log.Printf)
+	lc.OnShutdown("TLS certificate watch", func(context.Context) error { reloader.Stop(); return nil })
+
+	return tc, nil
+}
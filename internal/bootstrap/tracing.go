@@ -0,0 +1,18 @@
+package bootstrap
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/config"
+	"example/go-gin-library-api/internal/tracing"
+	"fmt"
+)
+
+// newTracing installs the tracer provider for the configured exporter.
+func newTracing(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	shutdown, err := tracing.Setup(ctx, tracing.Config{Exporter: cfg.Exporter, File: cfg.File})
+	if err != nil {
+		return nil, fmt.Errorf("tracing.Setup: %w", err)
+	}
+
+	return shutdown, nil
+}
@@ -0,0 +1,516 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Config is the whole application configuration. Values are resolved from, in
+// increasing order of precedence: defaults, the optional config file (YAML or
+// TOML), environment variables (.env is loaded if present) and command-line flags.
+type Config struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Auth      Auth      `yaml:"auth" toml:"auth"`
+	Store     Store     `yaml:"store" toml:"store"`
+	Patrons   Patrons   `yaml:"patrons" toml:"patrons"`
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	Audit     Audit     `yaml:"audit" toml:"audit"`
+	Tracing   Tracing   `yaml:"tracing" toml:"tracing"`
+}
+
+type Server struct {
+	Addr            string   `yaml:"addr" toml:"addr"`
+	PublicURL       string   `yaml:"public_url" toml:"public_url"` // base URL advertised in the OAuth metadata, e.g. https://library.example.com
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"` // how long in-flight requests get to finish after SIGINT/SIGTERM
+	TLS             TLS      `yaml:"tls" toml:"tls"`
+
+	// TrustedProxies are the addresses (or CIDRs) whose X-Forwarded-For is believed
+	// when finding the client IP. Empty means the peer address is always used.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// TLS is enabled when CertFile and KeyFile are set. The files are checked for
+// changes every ReloadInterval, so renewed certificates are picked up live.
+type TLS struct {
+	CertFile       string   `yaml:"cert_file" toml:"cert_file"`
+	KeyFile        string   `yaml:"key_file" toml:"key_file"`
+	MinVersion     string   `yaml:"min_version" toml:"min_version"` // 1.2 or 1.3
+	ReloadInterval Duration `yaml:"reload_interval" toml:"reload_interval"`
+
+	// ClientCAFile enables mutual TLS: clients may present a certificate signed by
+	// these CAs to authenticate (tls_client_auth) and get certificate-bound tokens.
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+}
+
+type Auth struct {
+	JWTSecret string  `yaml:"jwt_secret" toml:"jwt_secret"` // only used by HS256
+	Issuer    string  `yaml:"issuer" toml:"issuer"`
+	Audience  string  `yaml:"audience" toml:"audience"`
+	Signing   Signing `yaml:"signing" toml:"signing"`
+	Clients   Clients `yaml:"clients" toml:"clients"`
+	Tokens    Tokens  `yaml:"tokens" toml:"tokens"`
+
+	BruteForce BruteForce `yaml:"brute_force" toml:"brute_force"`
+
+	// External lists the identity providers whose access tokens are accepted
+	// too. It can only be set in the config file.
+	External []ExternalIssuer `yaml:"external" toml:"external"`
+
+	// ClientID is seeded into the client repository when it isn't registered yet,
+	// with either ClientSecret (hashed at startup) or an already hashed ClientSecretHash.
+	ClientID         string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret     string   `yaml:"client_secret" toml:"client_secret"`
+	ClientSecretHash string   `yaml:"client_secret_hash" toml:"client_secret_hash"`
+	ClientScopes     []string `yaml:"client_scopes" toml:"client_scopes"`
+	// ClientRedirectURIs let the configured client use the authorization code flow.
+	ClientRedirectURIs []string `yaml:"client_redirect_uris" toml:"client_redirect_uris"`
+	// ClientTLSSubject and ClientTLSSANs let it authenticate with a certificate.
+	ClientTLSSubject string   `yaml:"client_tls_subject" toml:"client_tls_subject"`
+	ClientTLSSANs    []string `yaml:"client_tls_sans" toml:"client_tls_sans"`
+}
+
+type Signing struct {
+	Algorithm string `yaml:"algorithm" toml:"algorithm"` // HS256, RS256, ES256 or EdDSA
+
+	// KeyFiles are PEM private keys; the first one signs, the others only verify.
+	KeyFiles []string `yaml:"key_files" toml:"key_files"`
+
+	// KeyDir holds the keys created by rotation (and any PEM dropped in). The newest one signs.
+	KeyDir string `yaml:"key_dir" toml:"key_dir"`
+
+	// RotationInterval enables scheduled rotation when positive. Retired keys keep
+	// verifying tokens for RotationOverlap, which must outlive the tokens they signed.
+	RotationInterval Duration `yaml:"rotation_interval" toml:"rotation_interval"`
+	RotationOverlap  Duration `yaml:"rotation_overlap" toml:"rotation_overlap"`
+}
+
+type Clients struct {
+	Driver   string `yaml:"driver" toml:"driver"` // memory, json or mysql
+	JSONPath string `yaml:"json_path" toml:"json_path"`
+	MySQLDSN string `yaml:"mysql_dsn" toml:"mysql_dsn"`
+}
+
+// ExternalIssuer is a trusted OpenID Connect provider, e.g. the company SSO.
+type ExternalIssuer struct {
+	Issuer      string              `yaml:"issuer" toml:"issuer"`     // discovery is fetched from <issuer>/.well-known/openid-configuration
+	Audience    string              `yaml:"audience" toml:"audience"` // required aud of its tokens
+	ScopeClaim  string              `yaml:"scope_claim" toml:"scope_claim"`
+	RolesClaim  string              `yaml:"roles_claim" toml:"roles_claim"` // dotted path for nested claims, e.g. realm_access.roles
+	Mappings    map[string][]string `yaml:"mappings" toml:"mappings"`       // scope or role value -> local scopes
+	JWKSRefresh Duration            `yaml:"jwks_refresh" toml:"jwks_refresh"`
+}
+
+type Tokens struct {
+	Driver     string   `yaml:"driver" toml:"driver"` // memory or json; holds refresh tokens and the revocation list
+	JSONPath   string   `yaml:"json_path" toml:"json_path"`
+	AccessTTL  Duration `yaml:"access_ttl" toml:"access_ttl"`
+	RefreshTTL Duration `yaml:"refresh_ttl" toml:"refresh_ttl"` // 0 disables refresh tokens
+}
+
+// BruteForce throttles failed client authentications per source IP and client:
+// after FreeAttempts every failure doubles the wait, starting at BaseDelay, and
+// LockoutAfter failures lock the IP out of that client for Lockout.
+type BruteForce struct {
+	FreeAttempts int      `yaml:"free_attempts" toml:"free_attempts"`
+	BaseDelay    Duration `yaml:"base_delay" toml:"base_delay"`
+	MaxDelay     Duration `yaml:"max_delay" toml:"max_delay"`
+	LockoutAfter int      `yaml:"lockout_after" toml:"lockout_after"` // 0 disables the lockout, not the backoff
+	Lockout      Duration `yaml:"lockout" toml:"lockout"`
+
+	// ClientAlertAfter failures of one client from any IP raise an alert (0 disables it).
+	ClientAlertAfter int `yaml:"client_alert_after" toml:"client_alert_after"`
+}
+
+type Store struct {
+	Driver      string `yaml:"driver" toml:"driver"` // memory, json, mysql, sqlite, postgres or bolt
+	MySQLDSN    string `yaml:"mysql_dsn" toml:"mysql_dsn"`
+	JSONPath    string `yaml:"json_path" toml:"json_path"`
+	SQLitePath  string `yaml:"sqlite_path" toml:"sqlite_path"`
+	BoltPath    string `yaml:"bolt_path" toml:"bolt_path"`
+	PostgresDSN string `yaml:"postgres_dsn" toml:"postgres_dsn"`
+	Migrate     bool   `yaml:"migrate" toml:"migrate"` // apply the SQL schema migrations at startup
+}
+
+type Patrons struct {
+	Driver   string `yaml:"driver" toml:"driver"` // memory, json or mysql
+	JSONPath string `yaml:"json_path" toml:"json_path"`
+	MySQLDSN string `yaml:"mysql_dsn" toml:"mysql_dsn"`
+}
+
+// RateLimit throttles requests with token buckets: per IP on the endpoints used
+// before authenticating, per client on /api. A zero limit disables it for the route.
+type RateLimit struct {
+	Backend   string `yaml:"backend" toml:"backend"`     // memory, or none to disable rate limiting
+	Token     Limit  `yaml:"token" toml:"token"`         // /auth/token, /auth/revoke and /auth/introspect
+	Authorize Limit  `yaml:"authorize" toml:"authorize"` // /auth/authorize and /patrons
+	API       Limit  `yaml:"api" toml:"api"`
+
+	// Tiers give the clients they list their own /api limit. They can only be set in the config file.
+	Tiers map[string]RateTier `yaml:"tiers" toml:"tiers"`
+}
+
+type RateTier struct {
+	Clients []string `yaml:"clients" toml:"clients"`
+	API     Limit    `yaml:"api" toml:"api"`
+}
+
+// Audit is the hash-chained trail of token requests, book changes and client administration.
+type Audit struct {
+	Sink     string `yaml:"sink" toml:"sink"` // file or mysql
+	Path     string `yaml:"path" toml:"path"` // JSON-lines file of the file sink
+	MySQLDSN string `yaml:"mysql_dsn" toml:"mysql_dsn"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter"` // none, stdout, file or otlp
+	File     string `yaml:"file" toml:"file"`
+}
+
+// Options are the flags that drive the loading itself rather than the application.
+type Options struct {
+	ConfigFile  string
+	PrintConfig bool
+	Args        []string // left after the flags, e.g. the cmd/migrate command
+}
+
+// Default returns the configuration used when nothing else is provided.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(15 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+			TLS: TLS{
+				MinVersion:     "1.2",
+				ReloadInterval: Duration(time.Minute),
+			},
+		},
+		Auth: Auth{
+			Issuer:       "go-gin-library-api",
+			Audience:     "go-gin-library-api",
+			ClientScopes: []string{"books:read", "books:write", "circulation", "admin", "openid", "profile"},
+			Signing: Signing{
+				Algorithm:       "HS256",
+				RotationOverlap: Duration(2 * time.Hour),
+			},
+			Clients: Clients{
+				Driver:   "memory",
+				JSONPath: "data/clients.json",
+			},
+			Tokens: Tokens{
+				Driver:     "memory",
+				JSONPath:   "data/tokens.json",
+				AccessTTL:  Duration(60 * time.Minute),
+				RefreshTTL: Duration(30 * 24 * time.Hour),
+			},
+			BruteForce: BruteForce{
+				FreeAttempts:     3,
+				BaseDelay:        Duration(time.Second),
+				MaxDelay:         Duration(time.Minute),
+				LockoutAfter:     10,
+				Lockout:          Duration(15 * time.Minute),
+				ClientAlertAfter: 50,
+			},
+		},
+		Store: Store{
+			Driver:     "memory",
+			JSONPath:   "data/books.json",
+			SQLitePath: "data/books.db",
+			BoltPath:   "data/books.bolt",
+			Migrate:    true,
+		},
+		Patrons: Patrons{
+			Driver:   "memory",
+			JSONPath: "data/patrons.json",
+		},
+		RateLimit: RateLimit{
+			Backend:   "memory",
+			Token:     Limit{Requests: 30, Per: Duration(time.Minute), Burst: 10},
+			Authorize: Limit{Requests: 30, Per: Duration(time.Minute), Burst: 10},
+			API:       Limit{Requests: 600, Per: Duration(time.Minute), Burst: 100},
+		},
+		Audit: Audit{
+			Sink: "file",
+			Path: "data/audit.jsonl",
+		},
+		Tracing: Tracing{
+			Exporter: "none",
+		},
+	}
+}
+
+// Load resolves the configuration from every source and the loading options from args.
+// The result is not validated, call Validate before using it.
+func Load(args []string) (Config, Options, error) {
+	var opts Options
+
+	// a .env file in the working directory is optional; it never overrides real variables
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, opts, fmt.Errorf("godotenv.Load: %w", err)
+	}
+
+	// flags are parsed into a scratch config first, they are applied last to win over everything else
+	scratch := Default()
+	fs := flag.NewFlagSet("booksrv", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the resolved configuration with secrets redacted and exit")
+	for _, f := range scratch.fields() {
+		fs.Var(f.value, f.flag, fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, opts, fmt.Errorf("fs.Parse: %w", err)
+	}
+
+	opts.Args = fs.Args()
+	cfg := Default()
+
+	if opts.ConfigFile != "" {
+		if err := loadFile(opts.ConfigFile, &cfg); err != nil {
+			return Config{}, opts, fmt.Errorf("loadFile: %w", err)
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return Config{}, opts, fmt.Errorf("loadEnv: %w", err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, f := range cfg.fields() {
+		if !set[f.flag] {
+			continue
+		}
+
+		if err := f.value.Set(fs.Lookup(f.flag).Value.String()); err != nil {
+			return Config{}, opts, fmt.Errorf("flag -%s: %w", f.flag, err)
+		}
+	}
+
+	return cfg, opts, nil
+}
+
+// loadFile decodes the config file on top of cfg, picking the format by extension.
+func loadFile(path string, cfg *Config) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(bytes, cfg)
+	case ".toml":
+		return toml.Unmarshal(bytes, cfg)
+	default:
+		return fmt.Errorf("unsupported config file extension %q", ext)
+	}
+}
+
+// loadEnv applies the environment variables on top of cfg.
+func loadEnv(cfg *Config) error {
+	for _, f := range cfg.fields() {
+		raw, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+
+		if err := f.value.Set(raw); err != nil {
+			return fmt.Errorf("variable %s: %w", f.env, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the whole configuration and reports every problem at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	required := func(value, name string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	positive := func(value Duration, name string) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+
+	required(c.Server.Addr, "server.addr")
+	if c.Server.PublicURL != "" {
+		if u, err := url.Parse(c.Server.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("server.public_url must be an absolute URL"))
+		}
+	}
+	positive(c.Server.ReadTimeout, "server.read_timeout")
+	positive(c.Server.WriteTimeout, "server.write_timeout")
+	positive(c.Server.IdleTimeout, "server.idle_timeout")
+	positive(c.Server.ShutdownTimeout, "server.shutdown_timeout")
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set together"))
+	}
+	if c.Server.TLS.ClientCAFile != "" && c.Server.TLS.CertFile == "" {
+		errs = append(errs, fmt.Errorf("server.tls.client_ca_file requires TLS (server.tls.cert_file)"))
+	}
+	switch c.Server.TLS.MinVersion {
+	case "1.2", "1.3":
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.min_version: unsupported version %q", c.Server.TLS.MinVersion))
+	}
+	if c.Server.TLS.CertFile != "" {
+		positive(c.Server.TLS.ReloadInterval, "server.tls.reload_interval")
+	}
+
+	switch c.Auth.Signing.Algorithm {
+	case "HS256":
+		required(c.Auth.JWTSecret, "auth.jwt_secret")
+		if c.Auth.Signing.RotationInterval > 0 {
+			errs = append(errs, fmt.Errorf("auth.signing.rotation_interval: HS256 keys can't be rotated, use an asymmetric algorithm"))
+		}
+	case "RS256", "ES256", "EdDSA":
+		if c.Auth.Signing.RotationInterval > 0 && c.Auth.Signing.RotationOverlap <= 0 {
+			errs = append(errs, fmt.Errorf("auth.signing.rotation_overlap must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.signing.algorithm: unknown algorithm %q", c.Auth.Signing.Algorithm))
+	}
+	required(c.Auth.Issuer, "auth.issuer")
+	required(c.Auth.Audience, "auth.audience")
+	if c.Auth.ClientSecret != "" && c.Auth.ClientSecretHash != "" {
+		errs = append(errs, fmt.Errorf("auth.client_secret and auth.client_secret_hash are mutually exclusive"))
+	}
+
+	switch strings.ToLower(c.Auth.Clients.Driver) {
+	case "memory":
+		// nothing survives a restart, so the seed client is the only way in
+		required(c.Auth.ClientID, "auth.client_id")
+		if c.Auth.ClientSecret == "" && c.Auth.ClientSecretHash == "" {
+			errs = append(errs, fmt.Errorf("auth.client_secret or auth.client_secret_hash is required"))
+		}
+	case "json":
+		required(c.Auth.Clients.JSONPath, "auth.clients.json_path")
+	case "mysql":
+		required(c.Auth.Clients.MySQLDSN, "auth.clients.mysql_dsn")
+	default:
+		errs = append(errs, fmt.Errorf("auth.clients.driver: unknown client repository %q", c.Auth.Clients.Driver))
+	}
+
+	for i, ext := range c.Auth.External {
+		name := fmt.Sprintf("auth.external[%d]", i)
+		if u, err := url.Parse(ext.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s.issuer must be an absolute URL", name))
+		}
+		if ext.Issuer == c.Auth.Issuer {
+			errs = append(errs, fmt.Errorf("%s.issuer can't be our own issuer", name))
+		}
+		required(ext.Audience, name+".audience")
+		if len(ext.Mappings) == 0 {
+			errs = append(errs, fmt.Errorf("%s.mappings: tokens would be granted no scope", name))
+		}
+	}
+
+	bf := c.Auth.BruteForce
+	positive(bf.BaseDelay, "auth.brute_force.base_delay")
+	positive(bf.Lockout, "auth.brute_force.lockout")
+	if bf.MaxDelay < bf.BaseDelay {
+		errs = append(errs, fmt.Errorf("auth.brute_force.max_delay can't be shorter than base_delay"))
+	}
+	if bf.FreeAttempts < 0 || bf.LockoutAfter < 0 || bf.ClientAlertAfter < 0 {
+		errs = append(errs, fmt.Errorf("auth.brute_force: attempt counts can't be negative"))
+	}
+	if bf.LockoutAfter > 0 && bf.LockoutAfter <= bf.FreeAttempts {
+		errs = append(errs, fmt.Errorf("auth.brute_force.lockout_after must be greater than free_attempts"))
+	}
+
+	positive(c.Auth.Tokens.AccessTTL, "auth.tokens.access_ttl")
+	if c.Auth.Tokens.RefreshTTL < 0 {
+		errs = append(errs, fmt.Errorf("auth.tokens.refresh_ttl can't be negative"))
+	}
+
+	switch strings.ToLower(c.Auth.Tokens.Driver) {
+	case "memory":
+	case "json":
+		required(c.Auth.Tokens.JSONPath, "auth.tokens.json_path")
+	default:
+		errs = append(errs, fmt.Errorf("auth.tokens.driver: unknown token store %q", c.Auth.Tokens.Driver))
+	}
+
+	switch strings.ToLower(c.Store.Driver) {
+	case "memory":
+	case "json":
+		required(c.Store.JSONPath, "store.json_path")
+	case "mysql":
+		required(c.Store.MySQLDSN, "store.mysql_dsn")
+	case "sqlite":
+		required(c.Store.SQLitePath, "store.sqlite_path")
+	case "postgres":
+		required(c.Store.PostgresDSN, "store.postgres_dsn")
+	case "bolt":
+		required(c.Store.BoltPath, "store.bolt_path")
+	default:
+		errs = append(errs, fmt.Errorf("store.driver: unknown store %q", c.Store.Driver))
+	}
+
+	switch strings.ToLower(c.Patrons.Driver) {
+	case "memory":
+	case "json":
+		required(c.Patrons.JSONPath, "patrons.json_path")
+	case "mysql":
+		required(c.Patrons.MySQLDSN, "patrons.mysql_dsn")
+	default:
+		errs = append(errs, fmt.Errorf("patrons.driver: unknown patron store %q", c.Patrons.Driver))
+	}
+
+	switch strings.ToLower(c.RateLimit.Backend) {
+	case "memory", "none":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.backend: unknown backend %q", c.RateLimit.Backend))
+	}
+	seenClients := map[string]string{}
+	for name, tier := range c.RateLimit.Tiers {
+		for _, id := range tier.Clients {
+			if other, ok := seenClients[id]; ok && other != name {
+				errs = append(errs, fmt.Errorf("rate_limit.tiers: client %q is in tiers %q and %q", id, other, name))
+			}
+			seenClients[id] = name
+		}
+	}
+
+	switch strings.ToLower(c.Audit.Sink) {
+	case "file":
+		required(c.Audit.Path, "audit.path")
+	case "mysql":
+		required(c.Audit.MySQLDSN, "audit.mysql_dsn")
+	default:
+		errs = append(errs, fmt.Errorf("audit.sink: unknown sink %q", c.Audit.Sink))
+	}
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "none", "stdout", "otlp":
+	case "file":
+		required(c.Tracing.File, "tracing.file")
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unknown exporter %q", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
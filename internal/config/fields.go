@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration written as a Go duration string ("15s") in config files.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// stringValue adapts a string field to flag.Value.
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+
+	return *v.p
+}
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+// intValue adapts an int field to flag.Value.
+type intValue struct{ p *int }
+
+func (v intValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*v.p)
+}
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+
+	*v.p = n
+	return nil
+}
+
+// boolValue adapts a bool field to flag.Value.
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+
+	return strconv.FormatBool(*v.p)
+}
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+
+	*v.p = b
+	return nil
+}
+
+// IsBoolFlag lets --migrate be passed without a value.
+func (v boolValue) IsBoolFlag() bool { return true }
+
+// listValue adapts a string slice field to flag.Value, as a comma-separated list.
+type listValue struct{ p *[]string }
+
+func (v listValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+
+	return strings.Join(*v.p, ",")
+}
+
+func (v listValue) Set(s string) error {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	*v.p = out
+	return nil
+}
+
+// Limit is a rate limit written "requests/period[:burst]", e.g. 600/1m:100.
+// The burst defaults to the number of requests; "0" or "" means no limit.
+type Limit struct {
+	Requests int
+	Per      Duration
+	Burst    int
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "0"
+	}
+
+	s := fmt.Sprintf("%d/%s", l.Requests, l.Per)
+	if l.Burst != 0 && l.Burst != l.Requests {
+		s += fmt.Sprintf(":%d", l.Burst)
+	}
+
+	return s
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+// Set implements flag.Value.
+func (l *Limit) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	requests, per, ok := strings.Cut(rate, "/")
+	if !ok {
+		return fmt.Errorf("invalid limit %q, want requests/period[:burst]", s)
+	}
+
+	var parsed Limit
+	var err error
+	if parsed.Requests, err = strconv.Atoi(requests); err != nil || parsed.Requests <= 0 {
+		return fmt.Errorf("invalid limit %q: requests must be a positive number", s)
+	}
+	if err := parsed.Per.Set(per); err != nil || parsed.Per <= 0 {
+		return fmt.Errorf("invalid limit %q: period must be a positive duration", s)
+	}
+	if hasBurst {
+		if parsed.Burst, err = strconv.Atoi(burst); err != nil || parsed.Burst <= 0 {
+			return fmt.Errorf("invalid limit %q: burst must be a positive number", s)
+		}
+	}
+
+	*l = parsed
+	return nil
+}
+
+type value interface {
+	String() string
+	Set(string) error
+}
+
+// field binds one setting to its environment variable and command-line flag.
+type field struct {
+	env    string
+	flag   string
+	usage  string
+	secret bool // redacted by --print-config
+	value  value
+}
+
+// fields lists every setting that can be overridden from the environment or flags.
+// The environment variable names are the ones the API has always used in .env.
+func (c *Config) fields() []field {
+	return []field{
+		{env: "ADDR", flag: "addr", usage: "address the HTTP server listens on", value: stringValue{&c.Server.Addr}},
+		{env: "PUBLIC_URL", flag: "public-url", usage: "base URL advertised in the OAuth server metadata", value: stringValue{&c.Server.PublicURL}},
+		{env: "READ_TIMEOUT", flag: "read-timeout", usage: "maximum duration for reading a request", value: &c.Server.ReadTimeout},
+		{env: "WRITE_TIMEOUT", flag: "write-timeout", usage: "maximum duration for writing a response", value: &c.Server.WriteTimeout},
+		{env: "IDLE_TIMEOUT", flag: "idle-timeout", usage: "keep-alive idle timeout", value: &c.Server.IdleTimeout},
+		{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "grace period to drain requests on shutdown", value: &c.Server.ShutdownTimeout},
+		{env: "TRUSTED_PROXIES", flag: "trusted-proxies", usage: "comma-separated proxy addresses or CIDRs whose X-Forwarded-For is trusted", value: listValue{&c.Server.TrustedProxies}},
+
+		{env: "TLS_CERT_FILE", flag: "tls-cert-file", usage: "PEM certificate (chain) served over TLS", value: stringValue{&c.Server.TLS.CertFile}},
+		{env: "TLS_KEY_FILE", flag: "tls-key-file", usage: "PEM private key of the TLS certificate", value: stringValue{&c.Server.TLS.KeyFile}},
+		{env: "TLS_MIN_VERSION", flag: "tls-min-version", usage: "minimum TLS version: 1.2 or 1.3", value: stringValue{&c.Server.TLS.MinVersion}},
+		{env: "TLS_RELOAD_INTERVAL", flag: "tls-reload-interval", usage: "how often the certificate files are checked for changes", value: &c.Server.TLS.ReloadInterval},
+		{env: "TLS_CLIENT_CA_FILE", flag: "tls-client-ca-file", usage: "PEM CAs of client certificates, enables mutual TLS", value: stringValue{&c.Server.TLS.ClientCAFile}},
+
+		{env: "JWT_SECRET", flag: "jwt-secret", usage: "HS256 signing secret", secret: true, value: stringValue{&c.Auth.JWTSecret}},
+		{env: "JWT_ALGORITHM", flag: "jwt-algorithm", usage: "token signing algorithm: HS256, RS256, ES256 or EdDSA", value: stringValue{&c.Auth.Signing.Algorithm}},
+		{env: "JWT_KEY_FILES", flag: "jwt-key-files", usage: "comma-separated PEM private keys, the first one signs", value: listValue{&c.Auth.Signing.KeyFiles}},
+		{env: "JWT_KEY_DIR", flag: "jwt-key-dir", usage: "directory of PEM private keys, written by rotation", value: stringValue{&c.Auth.Signing.KeyDir}},
+		{env: "JWT_ROTATION_INTERVAL", flag: "jwt-rotation-interval", usage: "how often a new signing key is generated (0 disables rotation)", value: &c.Auth.Signing.RotationInterval},
+		{env: "JWT_ROTATION_OVERLAP", flag: "jwt-rotation-overlap", usage: "how long a rotated-out key keeps verifying tokens", value: &c.Auth.Signing.RotationOverlap},
+		{env: "ISSUER", flag: "issuer", usage: "token issuer (iss)", value: stringValue{&c.Auth.Issuer}},
+		{env: "AUDIENCE", flag: "audience", usage: "token audience (aud)", value: stringValue{&c.Auth.Audience}},
+		{env: "CLIENT_ID", flag: "client-id", usage: "OAuth client id", value: stringValue{&c.Auth.ClientID}},
+		{env: "CLIENT_SECRET", flag: "client-secret", usage: "OAuth client secret", secret: true, value: stringValue{&c.Auth.ClientSecret}},
+		{env: "CLIENT_SECRET_HASH", flag: "client-secret-hash", usage: "argon2id or bcrypt hash of the OAuth client secret", secret: true, value: stringValue{&c.Auth.ClientSecretHash}},
+		{env: "CLIENT_SCOPES", flag: "client-scopes", usage: "comma-separated scopes of the configured client", value: listValue{&c.Auth.ClientScopes}},
+		{env: "CLIENT_REDIRECT_URIS", flag: "client-redirect-uris", usage: "comma-separated redirect URIs of the configured client", value: listValue{&c.Auth.ClientRedirectURIs}},
+		{env: "CLIENT_TLS_SUBJECT", flag: "client-tls-subject", usage: "certificate subject DN the configured client can authenticate with", value: stringValue{&c.Auth.ClientTLSSubject}},
+		{env: "CLIENT_TLS_SANS", flag: "client-tls-sans", usage: "comma-separated certificate SANs the configured client can authenticate with", value: listValue{&c.Auth.ClientTLSSANs}},
+		{env: "CLIENT_STORE", flag: "client-store", usage: "client repository: memory, json or mysql", value: stringValue{&c.Auth.Clients.Driver}},
+		{env: "CLIENT_JSON_PATH", flag: "client-json-path", usage: "path of the JSON client repository file", value: stringValue{&c.Auth.Clients.JSONPath}},
+		{env: "CLIENT_MYSQL_DSN", flag: "client-mysql-dsn", usage: "MySQL data source name of the client repository", secret: true, value: stringValue{&c.Auth.Clients.MySQLDSN}},
+		{env: "TOKEN_STORE", flag: "token-store", usage: "refresh token and revocation store: memory or json", value: stringValue{&c.Auth.Tokens.Driver}},
+		{env: "TOKEN_JSON_PATH", flag: "token-json-path", usage: "path of the JSON token store file", value: stringValue{&c.Auth.Tokens.JSONPath}},
+		{env: "ACCESS_TOKEN_TTL", flag: "access-token-ttl", usage: "lifetime of access tokens", value: &c.Auth.Tokens.AccessTTL},
+		{env: "REFRESH_TOKEN_TTL", flag: "refresh-token-ttl", usage: "lifetime of refresh tokens (0 disables them)", value: &c.Auth.Tokens.RefreshTTL},
+		{env: "AUTH_FREE_ATTEMPTS", flag: "auth-free-attempts", usage: "failed client authentications per IP before the backoff starts", value: intValue{&c.Auth.BruteForce.FreeAttempts}},
+		{env: "AUTH_BACKOFF_BASE", flag: "auth-backoff-base", usage: "first backoff after a failed client authentication, doubled by each further failure", value: &c.Auth.BruteForce.BaseDelay},
+		{env: "AUTH_BACKOFF_MAX", flag: "auth-backoff-max", usage: "longest backoff", value: &c.Auth.BruteForce.MaxDelay},
+		{env: "AUTH_LOCKOUT_AFTER", flag: "auth-lockout-after", usage: "failed client authentications that lock the IP out of the client (0 disables it)", value: intValue{&c.Auth.BruteForce.LockoutAfter}},
+		{env: "AUTH_LOCKOUT_DURATION", flag: "auth-lockout-duration", usage: "length of a lockout, also how long failures are remembered", value: &c.Auth.BruteForce.Lockout},
+		{env: "AUTH_ALERT_AFTER", flag: "auth-alert-after", usage: "failed authentications of one client from any IP that raise an alert (0 disables it)", value: intValue{&c.Auth.BruteForce.ClientAlertAfter}},
+
+		{env: "BOOK_STORE", flag: "store", usage: "book store: memory, json, mysql, sqlite, postgres or bolt", value: stringValue{&c.Store.Driver}},
+		{env: "BOOK_MYSQL_DSN", flag: "mysql-dsn", usage: "MySQL data source name", secret: true, value: stringValue{&c.Store.MySQLDSN}},
+		{env: "BOOK_JSON_PATH", flag: "json-path", usage: "path of the JSON store file", value: stringValue{&c.Store.JSONPath}},
+		{env: "BOOK_SQLITE_PATH", flag: "sqlite-path", usage: "path of the SQLite database file", value: stringValue{&c.Store.SQLitePath}},
+		{env: "BOOK_MIGRATE", flag: "migrate", usage: "apply the SQL store schema migrations at startup", value: boolValue{&c.Store.Migrate}},
+		{env: "BOOK_BOLT_PATH", flag: "bolt-path", usage: "path of the bolt key-value store file", value: stringValue{&c.Store.BoltPath}},
+		{env: "BOOK_POSTGRES_DSN", flag: "postgres-dsn", usage: "PostgreSQL connection string", secret: true, value: stringValue{&c.Store.PostgresDSN}},
+
+		{env: "PATRON_STORE", flag: "patron-store", usage: "patron store: memory, json or mysql", value: stringValue{&c.Patrons.Driver}},
+		{env: "PATRON_JSON_PATH", flag: "patron-json-path", usage: "path of the JSON patron store file", value: stringValue{&c.Patrons.JSONPath}},
+		{env: "PATRON_MYSQL_DSN", flag: "patron-mysql-dsn", usage: "MySQL data source name of the patron store", secret: true, value: stringValue{&c.Patrons.MySQLDSN}},
+
+		{env: "RATE_LIMIT_BACKEND", flag: "rate-limit-backend", usage: "rate limit backend: memory, or none to disable", value: stringValue{&c.RateLimit.Backend}},
+		{env: "RATE_LIMIT_TOKEN", flag: "rate-limit-token", usage: "per-IP limit of the token, revoke and introspect endpoints (requests/period[:burst])", value: &c.RateLimit.Token},
+		{env: "RATE_LIMIT_AUTHORIZE", flag: "rate-limit-authorize", usage: "per-IP limit of the authorize and patron sign-up endpoints", value: &c.RateLimit.Authorize},
+		{env: "RATE_LIMIT_API", flag: "rate-limit-api", usage: "per-client limit of /api", value: &c.RateLimit.API},
+
+		{env: "AUDIT_SINK", flag: "audit-sink", usage: "audit trail sink: file or mysql", value: stringValue{&c.Audit.Sink}},
+		{env: "AUDIT_PATH", flag: "audit-path", usage: "JSON-lines file of the file audit sink", value: stringValue{&c.Audit.Path}},
+		{env: "AUDIT_MYSQL_DSN", flag: "audit-mysql-dsn", usage: "MySQL data source name of the audit sink", secret: true, value: stringValue{&c.Audit.MySQLDSN}},
+
+		{env: "OTEL_TRACES_EXPORTER", flag: "traces-exporter", usage: "trace exporter: none, stdout, file or otlp", value: stringValue{&c.Tracing.Exporter}},
+		{env: "TRACES_FILE", flag: "traces-file", usage: "destination of the file trace exporter", value: stringValue{&c.Tracing.File}},
+	}
+}
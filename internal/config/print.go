@@ -0,0 +1,32 @@
+package config
+
+import (
+	"io"
+
+	"github.com/goccy/go-yaml"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of the configuration with every secret replaced.
+func (c Config) Redacted() Config {
+	out := c
+	for _, f := range out.fields() {
+		if f.secret && f.value.String() != "" {
+			f.value.Set(redacted)
+		}
+	}
+
+	return out
+}
+
+// Print writes the redacted configuration as YAML, the format accepted by --config.
+func (c Config) Print(w io.Writer) error {
+	bytes, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(bytes)
+	return err
+}
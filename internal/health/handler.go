@@ -0,0 +1,36 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	registry *Registry
+}
+
+func NewHandler(registry *Registry) *Handler {
+	h := Handler{
+		registry: registry,
+	}
+
+	return &h
+}
+
+// Liveness reports that the process is running and able to serve requests.
+func (h *Handler) Liveness(ctx *gin.Context) {
+	ctx.IndentedJSON(http.StatusOK, gin.H{"status": StatusUp})
+}
+
+// Readiness runs every registered checker and returns 503 if any component is down.
+func (h *Handler) Readiness(ctx *gin.Context) {
+	report := h.registry.Run(ctx)
+
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.IndentedJSON(status, report)
+}
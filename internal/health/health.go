@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Checker reports whether a dependency is usable. A nil error means healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets ordinary functions be registered as checkers.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Registry keeps the named checkers used by the readiness endpoint.
+type Registry struct {
+	mu       sync.RWMutex
+	checkers map[string]Checker
+	timeout  time.Duration
+}
+
+// NewRegistry creates an empty registry; each check is bounded by timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{checkers: map[string]Checker{}, timeout: timeout}
+}
+
+// Register adds (or replaces) the checker for a component.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers[name] = c
+}
+
+// Run executes every checker concurrently and aggregates their results.
+// The report is up only if every component is up.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(r.checkers))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, c := range r.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Check(checkCtx)
+			status := ComponentStatus{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				status.Status = StatusDown
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Components[name] = status
+			if err != nil {
+				report.Status = StatusDown
+			}
+		}()
+	}
+
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "library"
+
+// Metrics groups every collector exposed by the API on /metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration  *prometheus.HistogramVec
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+
+	checkouts    prometheus.Counter
+	returns      prometheus.Counter
+	unavailable  prometheus.Counter
+	tokensIssued prometheus.Counter
+	tokensDenied *prometheus.CounterVec
+	authAlerts   *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them, together with the default
+// Go runtime and process collectors, on a dedicated registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of book store operations by backend and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "method"}),
+
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "errors_total",
+			Help:      "Book store operations that returned an error, by backend and method.",
+		}, []string{"backend", "method"}),
+
+		checkouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "books",
+			Name:      "checkouts_total",
+			Help:      "Books successfully checked out.",
+		}),
+
+		returns: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "books",
+			Name:      "returns_total",
+			Help:      "Books successfully returned.",
+		}),
+
+		unavailable: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "books",
+			Name:      "checkout_unavailable_total",
+			Help:      "Checkouts rejected because the book had no copies left.",
+		}),
+
+		tokensIssued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "tokens_issued_total",
+			Help:      "Access tokens issued by the token endpoint.",
+		}),
+
+		tokensDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "token_failures_total",
+			Help:      "Token requests that did not issue a token, by reason.",
+		}, []string{"reason"}),
+
+		authAlerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "bruteforce_alerts_total",
+			Help:      "Brute-force alerts raised on client authentication, by kind (lockout, client_targeted).",
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.storeDuration,
+		m.storeErrors,
+		m.checkouts,
+		m.returns,
+		m.unavailable,
+		m.tokensIssued,
+		m.tokensDenied,
+		m.authAlerts,
+	)
+
+	return m
+}
+
+// AuthAlert counts a brute-force alert of the given kind.
+func (m *Metrics) AuthAlert(kind string) {
+	m.authAlerts.WithLabelValues(kind).Inc()
+}
+
+// Handler returns the http.Handler serving the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP records the duration of every request, labelled by the matched route
+// template (e.g. /api/books/:id) so ids don't explode the label cardinality.
+func (m *Metrics) HTTP() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(ctx.Writer.Status())
+		m.httpDuration.WithLabelValues(ctx.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Tokens counts the outcome of the token endpoint. Failure reasons come from the
+// errors attached to the context by the auth handler.
+func (m *Metrics) Tokens() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if ctx.Writer.Status() == http.StatusOK {
+			m.tokensIssued.Inc()
+			return
+		}
+
+		reason := http.StatusText(ctx.Writer.Status())
+		if last := ctx.Errors.Last(); last != nil {
+			reason = last.Error()
+		}
+
+		m.tokensDenied.WithLabelValues(reason).Inc()
+	}
+}
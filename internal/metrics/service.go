@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/book"
+)
+
+// Service decorates a book.Service with the circulation business counters.
+type Service struct {
+	book.Service
+	m *Metrics
+}
+
+// NewService wraps next; methods without counters are promoted from the embedded service.
+func (m *Metrics) NewService(next book.Service) *Service {
+	return &Service{Service: next, m: m}
+}
+
+func (s *Service) Checkout(ctx context.Context, id string) (book.Book, error) {
+	out, err := s.Service.Checkout(ctx, id)
+
+	switch {
+	case err == nil:
+		s.m.checkouts.Inc()
+	case errors.Is(err, book.ErrBookUnavailable):
+		s.m.unavailable.Inc()
+	}
+
+	return out, err
+}
+
+func (s *Service) Return(ctx context.Context, id string) (book.Book, error) {
+	out, err := s.Service.Return(ctx, id)
+	if err == nil {
+		s.m.returns.Inc()
+	}
+
+	return out, err
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+	"time"
+)
+
+// Store decorates a book.Store, recording latency and errors for each method.
+type Store struct {
+	next    book.Store
+	backend string
+	m       *Metrics
+}
+
+// NewStore wraps next; backend is used as the label value (e.g. "mysql").
+func (m *Metrics) NewStore(next book.Store, backend string) *Store {
+	return &Store{next: next, backend: backend, m: m}
+}
+
+// observe records the duration and outcome of one store call.
+func (s *Store) observe(method string, start time.Time, err error) {
+	s.m.storeDuration.WithLabelValues(s.backend, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.m.storeErrors.WithLabelValues(s.backend, method).Inc()
+	}
+}
+
+func (s *Store) List(ctx context.Context) ([]book.Book, error) {
+	start := time.Now()
+	out, err := s.next.List(ctx)
+	s.observe("List", start, err)
+	return out, err
+}
+
+func (s *Store) FindById(ctx context.Context, id string) (book.Book, error) {
+	start := time.Now()
+	out, err := s.next.FindById(ctx, id)
+	s.observe("FindById", start, err)
+	return out, err
+}
+
+func (s *Store) Create(ctx context.Context, b book.Book) (string, error) {
+	start := time.Now()
+	out, err := s.next.Create(ctx, b)
+	s.observe("Create", start, err)
+	return out, err
+}
+
+func (s *Store) Update(ctx context.Context, b book.Book) error {
+	start := time.Now()
+	err := s.next.Update(ctx, b)
+	s.observe("Update", start, err)
+	return err
+}
+
+// Modify goes through book.Modify, so it stays atomic when the wrapped store is a book.Modifier.
+func (s *Store) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	start := time.Now()
+	out, err := book.Modify(ctx, s.next, id, fn)
+	s.observe("Modify", start, err)
+	return out, err
+}
+
+// WithTx records the whole transaction, and each call made within it.
+func (s *Store) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	start := time.Now()
+	err := s.next.WithTx(ctx, func(tx book.Store) error {
+		return fn(&Store{next: tx, backend: s.backend, m: s.m})
+	})
+	s.observe("WithTx", start, err)
+	return err
+}
+
+func (s *Store) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	start := time.Now()
+	out, err := s.next.FindByTitle(ctx, title)
+	s.observe("FindByTitle", start, err)
+	return out, err
+}
+
+func (s *Store) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	start := time.Now()
+	out, err := s.next.FindByAuthor(ctx, author)
+	s.observe("FindByAuthor", start, err)
+	return out, err
+}
+
+func (s *Store) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// pgLockKey is the first half of the advisory lock keys for Postgres, which
+// locks on numbers; the second is a hash of the history table name.
+const pgLockKey = 7_240_116
+
+// Dialect is what differs between the supported databases.
+type Dialect interface {
+	lock(ctx context.Context, conn *sql.Conn, name string) error
+	unlock(ctx context.Context, conn *sql.Conn, name string) error
+	createTable(name string) string
+	placeholder(n int) string
+	transactionalDDL() bool
+}
+
+var (
+	MySQL    Dialect = mysqlDialect{}
+	Postgres Dialect = postgresDialect{}
+	SQLite   Dialect = sqliteDialect{}
+)
+
+type mysqlDialect struct{}
+
+// mysqlLockName is the name of the lock on the history table name in the
+// current database. MySQL locks are server-wide, without the database two
+// schemas on a server would wait for each other.
+const mysqlLockName = `CONCAT_WS('.', DATABASE(), ?)`
+
+// lock takes a named lock, held by the connection until released.
+func (mysqlDialect) lock(ctx context.Context, conn *sql.Conn, name string) error {
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(`+mysqlLockName+`, 60);`, name).Scan(&got); err != nil {
+		return err
+	}
+
+	if got.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for lock %q", name)
+	}
+
+	return nil
+}
+
+func (mysqlDialect) unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(`+mysqlLockName+`);`, name)
+	return err
+}
+
+func (mysqlDialect) createTable(name string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + name + ` (
+		version bigint NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		checksum char(64) NOT NULL,
+		applied_at datetime(6) NOT NULL
+	);`
+}
+
+func (mysqlDialect) placeholder(int) string { return "?" }
+
+// MySQL commits implicitly around DDL, a transaction wouldn't undo it.
+func (mysqlDialect) transactionalDDL() bool { return false }
+
+type postgresDialect struct{}
+
+// lock takes a session advisory lock, waiting for the instance holding it.
+func (postgresDialect) lock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1, hashtext($2));`, pgLockKey, name)
+	return err
+}
+
+func (postgresDialect) unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, hashtext($2));`, pgLockKey, name)
+	return err
+}
+
+func (postgresDialect) createTable(name string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + name + ` (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		checksum char(64) NOT NULL,
+		applied_at timestamptz NOT NULL
+	);`
+}
+
+func (postgresDialect) placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) transactionalDDL() bool { return true }
+
+type sqliteDialect struct{}
+
+// lock does nothing: every migration runs in a transaction that also inserts
+// its version, so a second process racing for it fails and rolls back.
+func (sqliteDialect) lock(context.Context, *sql.Conn, string) error { return nil }
+
+func (sqliteDialect) unlock(context.Context, *sql.Conn, string) error { return nil }
+
+func (sqliteDialect) createTable(name string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + name + ` (
+		version integer PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		checksum char(64) NOT NULL,
+		applied_at timestamp NOT NULL
+	);`
+}
+
+func (sqliteDialect) placeholder(int) string { return "?" }
+
+func (sqliteDialect) transactionalDDL() bool { return true }
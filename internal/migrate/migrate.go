@@ -0,0 +1,328 @@
+// Package migrate applies versioned SQL migrations, embedded in the binary, to
+// the SQL stores. Applied versions are recorded in a history table
+// (schema_migrations by default) with the checksum of their up file, so a
+// migration edited after it ran is caught instead of silently diverging
+// between databases.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChecksum     = fmt.Errorf("applied migration was changed")
+	ErrUnknown      = fmt.Errorf("database has a migration this binary doesn't know")
+	ErrIrreversible = fmt.Errorf("migration has no down file")
+)
+
+// fileName is "<version>_<name>.<up|down>.sql", e.g. 0002_add_isbn.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// tableName is what a history table may be called, it is spliced into the SQL.
+var tableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Migration is one version of the schema. Down is empty when it can't be rolled back.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+// Status is a migration, and when it was applied if it was.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load reads the migrations at the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadDir: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s: version must be a positive number", entry.Name())
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is already taken by %s", entry.Name(), version, m.Name)
+		}
+
+		bytes, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("fs.ReadFile: %w", err)
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(bytes)
+			m.Up, m.Checksum = string(bytes), hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(bytes)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+
+	return out, nil
+}
+
+// Migrator applies migrations to a database. Every run holds a lock in the
+// database, so instances starting together apply each migration only once.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	history    string
+	migrations []Migration
+}
+
+// New loads the migrations of fsys for db, recorded in schema_migrations.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	return NewWithHistory(db, dialect, fsys, "schema_migrations")
+}
+
+// NewWithHistory is New recording the applied versions in the table history
+// instead, so the schemas of several components can share a database, each
+// numbered from 1.
+func NewWithHistory(db *sql.DB, dialect Dialect, fsys fs.FS, history string) (*Migrator, error) {
+	if !tableName.MatchString(history) {
+		return nil, fmt.Errorf("invalid history table name %q", history)
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	return &Migrator{db: db, dialect: dialect, history: history, migrations: migrations}, nil
+}
+
+// Up applies the pending migrations in order and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		for _, mig := range m.migrations {
+			if applied[mig.Version].Applied {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mig, mig.Up, true); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if !applied[mig.Version].Applied {
+				continue
+			}
+
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, ErrIrreversible)
+			}
+
+			if err := m.apply(ctx, conn, mig, mig.Down, false); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every migration, and whether it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		for _, mig := range m.migrations {
+			s := applied[mig.Version]
+			s.Migration = mig
+			out = append(out, s)
+		}
+		return nil
+	})
+
+	return out, err
+}
+
+// locked runs fn on a single connection holding the migration lock, with the
+// applied migrations already checked against the files.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn, map[int64]Status) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Conn: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn, m.history); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	defer m.dialect.unlock(context.WithoutCancel(ctx), conn, m.history)
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable(m.history)); err != nil {
+		return fmt.Errorf("create %s: %w", m.history, err)
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("applied: %w", err)
+	}
+
+	return fn(conn, applied)
+}
+
+// applied reads the history table, and verifies it against the migration files.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM `+m.history+`;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := map[int64]Migration{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	applied := map[int64]Status{}
+	for rows.Next() {
+		var (
+			s        Status
+			checksum string
+		)
+		if err := rows.Scan(&s.Version, &checksum, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+
+		mig, ok := known[s.Version]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: version %d", ErrUnknown, s.Version)
+		case mig.Checksum != checksum:
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksum, mig.Version, mig.Name)
+		}
+
+		s.Applied = true
+		applied[s.Version] = s
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs the statements of script and records the migration as applied
+// (up) or not (down). Where the database allows DDL in a transaction, a
+// failing migration leaves nothing behind.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, script string, up bool) error {
+	var exec interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+	} = conn
+
+	var tx *sql.Tx
+	if m.dialect.transactionalDDL() {
+		var err error
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		exec = tx
+	}
+
+	for _, stmt := range split(script) {
+		if _, err := exec.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if up {
+		q := fmt.Sprintf(`INSERT INTO %s (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s);`, m.history,
+			m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3), m.dialect.placeholder(4))
+		_, err = exec.ExecContext(ctx, q, mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+	} else {
+		q := fmt.Sprintf(`DELETE FROM %s WHERE version = %s;`, m.history, m.dialect.placeholder(1))
+		_, err = exec.ExecContext(ctx, q, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	if tx != nil {
+		return tx.Commit()
+	}
+
+	return nil
+}
+
+// split cuts a script into statements, each ending with a semicolon at the
+// end of a line. Lines that are only a -- comment are dropped.
+func split(script string) []string {
+	var (
+		out  []string
+		stmt strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSpace(stmt.String()))
+			stmt.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(stmt.String()); rest != "" {
+		out = append(out, rest)
+	}
+
+	return out
+}
@@ -0,0 +1,306 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+// library is three migrations, the last one without a down file.
+func library() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_books.up.sql":     {Data: []byte("CREATE TABLE books (id text PRIMARY KEY, title text NOT NULL);\n")},
+		"0001_books.down.sql":   {Data: []byte("DROP TABLE books;\n")},
+		"0002_authors.up.sql":   {Data: []byte("-- the authors, one row each\nCREATE TABLE authors (\n\tid text PRIMARY KEY,\n\tname text NOT NULL\n);\nALTER TABLE books ADD COLUMN author_id text;\n")},
+		"0002_authors.down.sql": {Data: []byte("ALTER TABLE books DROP COLUMN author_id;\nDROP TABLE authors;\n")},
+		"0003_seed.up.sql":      {Data: []byte("INSERT INTO authors (id, name) VALUES ('a1', 'Tolstoy; Leo');\n")},
+		"README.md":             {Data: []byte("not a migration")},
+	}
+}
+
+// openDB returns an empty in-memory database. It has a single connection,
+// each one would get a database of its own.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+
+	m, err := New(db, SQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func versions(migrations []Migration) []int64 {
+	out := []int64{}
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+// tables returns the tables of db, except the history.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	out := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := newMigrator(t, db, library())
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Fatalf("Up applied %v, want 1, 2, 3", got)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"authors", "books"}) {
+		t.Fatalf("tables after Up = %v", got)
+	}
+
+	var name string
+	if err := db.QueryRow(`SELECT name FROM authors WHERE id = 'a1';`).Scan(&name); err != nil || name != "Tolstoy; Leo" {
+		t.Fatalf("seeded author = %q, %v", name, err)
+	}
+
+	// nothing left to do
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %v, %v, want nothing", versions(done), err)
+	}
+
+	// 3 has no down file, so rolling it back stops there
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("Down over 3 = %v, want %v", err, ErrIrreversible)
+	}
+
+	fsys := library()
+	fsys["0003_seed.down.sql"] = &fstest.MapFile{Data: []byte("DELETE FROM authors WHERE id = 'a1';\n")}
+	m = newMigrator(t, db, fsys)
+
+	done, err = m.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !slices.Equal(got, []int64{3, 2}) {
+		t.Fatalf("Down(2) rolled back %v, want 3, 2", got)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"books"}) {
+		t.Fatalf("tables after Down(2) = %v", got)
+	}
+
+	// Up picks up from there
+	done, err = m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !slices.Equal(got, []int64{2, 3}) {
+		t.Fatalf("Up after Down(2) applied %v, want 2, 3", got)
+	}
+}
+
+func TestFailingMigrationLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	fsys := library()
+	fsys["0002_authors.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE authors (id text PRIMARY KEY);\nINSERT INTO no_such_table VALUES (1);\n")}
+	m := newMigrator(t, db, fsys)
+
+	done, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("Up with a failing migration succeeded")
+	}
+	if got := versions(done); !slices.Equal(got, []int64{1}) {
+		t.Errorf("Up applied %v, want 1", got)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"books"}) {
+		t.Errorf("tables after the failure = %v, want books alone", got)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	fsys := library()
+	delete(fsys, "0002_authors.up.sql")
+	delete(fsys, "0002_authors.down.sql")
+	delete(fsys, "0003_seed.up.sql")
+	if _, err := newMigrator(t, db, fsys).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := newMigrator(t, db, library()).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 3 {
+		t.Fatalf("Status = %+v, want 3 migrations", status)
+	}
+	for i, s := range status {
+		applied := i == 0
+		if s.Version != int64(i+1) || s.Applied != applied || s.AppliedAt.IsZero() == applied {
+			t.Errorf("status %d = version %d, applied %t at %s", i, s.Version, s.Applied, s.AppliedAt)
+		}
+	}
+	if status[1].Name != "authors" || status[1].Checksum == "" {
+		t.Errorf("status of 2 = %+v", status[1])
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if _, err := newMigrator(t, db, library()).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := library()
+	fsys["0002_authors.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE authors (id text PRIMARY KEY, name text);\n")}
+	m := newMigrator(t, db, fsys)
+
+	if _, err := m.Up(ctx); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Up with an edited migration = %v, want %v", err, ErrChecksum)
+	}
+	if _, err := m.Status(ctx); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Status with an edited migration = %v, want %v", err, ErrChecksum)
+	}
+
+	// editing a down file is fine, it isn't checksummed
+	fsys = library()
+	fsys["0001_books.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE IF EXISTS books;\n")}
+	if _, err := newMigrator(t, db, fsys).Status(ctx); err != nil {
+		t.Errorf("Status with an edited down file = %v", err)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if _, err := newMigrator(t, db, library()).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// an older binary, which doesn't know 3
+	fsys := library()
+	delete(fsys, "0003_seed.up.sql")
+	m := newMigrator(t, db, fsys)
+
+	if _, err := m.Up(ctx); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Up = %v, want %v", err, ErrUnknown)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Down = %v, want %v", err, ErrUnknown)
+	}
+}
+
+func TestHistoryTables(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if _, err := newMigrator(t, db, library()).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// another component, numbered from 1 too, in the same database
+	other := fstest.MapFS{"0001_patrons.up.sql": {Data: []byte("CREATE TABLE patrons (id text PRIMARY KEY);\n")}}
+	m, err := NewWithHistory(db, SQLite, other, "schema_migrations_patrons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != 1 {
+		t.Fatalf("Up of the other history = %v, %v", versions(done), err)
+	}
+
+	if _, err := NewWithHistory(db, SQLite, other, "history; DROP TABLE books"); err == nil {
+		t.Error("NewWithHistory took a history name that isn't a table name")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"down without up": {"0001_books.down.sql": {Data: []byte("DROP TABLE books;")}},
+		"taken version": {
+			"0001_books.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_authors.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"zero version": {"0000_books.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+
+	for name, fsys := range tests {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"", nil},
+		{"SELECT 1;", []string{"SELECT 1;"}},
+		{
+			"-- two statements\nCREATE TABLE a (id int);\n\nCREATE TABLE b (\n  id int\n);\n",
+			[]string{"CREATE TABLE a (id int);", "CREATE TABLE b (\n  id int\n);"},
+		},
+		{
+			// a semicolon inside a line doesn't end the statement
+			"INSERT INTO a (v) VALUES ('x;y'), ('z');\nUPDATE a SET v = 'w;' WHERE v = 'z';\n",
+			[]string{"INSERT INTO a (v) VALUES ('x;y'), ('z');", "UPDATE a SET v = 'w;' WHERE v = 'z';"},
+		},
+		{
+			// nor does one at the end of a comment line
+			"SELECT 1\n-- done;\nFROM a;\n",
+			[]string{"SELECT 1\nFROM a;"},
+		},
+		{"SELECT 1;\nSELECT 2", []string{"SELECT 1;", "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		if got := split(tt.script); !slices.Equal(got, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package patron
+
+import "fmt"
+
+var (
+	ErrNotFound           = fmt.Errorf("patron not found")
+	ErrDuplicate          = fmt.Errorf("a patron with this email already exists")
+	ErrInvalidCredentials = fmt.Errorf("invalid email or password")
+	ErrWeakPassword       = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrInvalidEmail       = fmt.Errorf("invalid email address")
+)
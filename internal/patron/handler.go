@@ -0,0 +1,70 @@
+package patron
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	h := Handler{
+		service: service,
+	}
+
+	return &h
+}
+
+// Register signs up a new patron.
+func (h *Handler) Register(ctx *gin.Context) {
+	var request RegisterRequest
+
+	if err := ctx.BindJSON(&request); err != nil {
+		text := fmt.Sprintf("BindJSON: %s", err.Error())
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": text})
+		return
+	}
+
+	p, err := h.service.Register(ctx, request)
+	switch {
+	case errors.Is(err, ErrInvalidEmail), errors.Is(err, ErrWeakPassword):
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDuplicate):
+		ctx.IndentedJSON(http.StatusConflict, gin.H{"error": ErrDuplicate.Error()})
+		return
+	case err != nil:
+		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusCreated, newPatronResponse(p))
+}
+
+// Me returns the profile of the patron the token was issued to (its sub).
+// Tokens obtained with client credentials have no patron and get a 403.
+func (h *Handler) Me(ctx *gin.Context) {
+	sub := ctx.GetString("sub")
+	if sub == "" {
+		ctx.IndentedJSON(http.StatusForbidden, gin.H{"error": "token was not issued to a patron"})
+		return
+	}
+
+	p, err := h.service.GetById(ctx, sub)
+	if errors.Is(err, ErrNotFound) {
+		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": ErrNotFound.Error()})
+		return
+	}
+
+	if err != nil {
+		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, newPatronResponse(p))
+}
@@ -0,0 +1,30 @@
+package patron
+
+import "time"
+
+// Patron is a library member. Only the hash of the password is stored.
+type Patron struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"` // stored lower-cased, unique
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PatronResponse is the public profile of a patron, without the password hash.
+type PatronResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newPatronResponse(p Patron) PatronResponse {
+	return PatronResponse{ID: p.ID, Email: p.Email, Name: p.Name, CreatedAt: p.CreatedAt}
+}
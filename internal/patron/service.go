@@ -0,0 +1,111 @@
+package patron
+
+import (
+	"context"
+	"errors"
+	"example/go-gin-library-api/internal/secret"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const minPasswordLength = 10
+
+type Service interface {
+	Register(ctx context.Context, request RegisterRequest) (Patron, error)
+	Authenticate(ctx context.Context, email, password string) (Patron, error)
+	GetById(ctx context.Context, id string) (Patron, error)
+}
+
+type PatronService struct {
+	store Store
+}
+
+func NewService(store Store) Service {
+	s := PatronService{
+		store: store,
+	}
+
+	return &s
+}
+
+// NormalizeEmail is the form emails are stored and looked up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Register creates a patron, hashing the password.
+func (s *PatronService) Register(ctx context.Context, request RegisterRequest) (Patron, error) {
+	email := NormalizeEmail(request.Email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return Patron{}, ErrInvalidEmail
+	}
+
+	if len(request.Password) < minPasswordLength {
+		return Patron{}, ErrWeakPassword
+	}
+
+	hash, err := secret.Hash(request.Password)
+	if err != nil {
+		return Patron{}, fmt.Errorf("secret.Hash: %w", err)
+	}
+
+	p := Patron{
+		ID:           uuid.NewString(),
+		Email:        email,
+		Name:         strings.TrimSpace(request.Name),
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.store.Create(ctx, p); err != nil {
+		return Patron{}, fmt.Errorf("store.Create: %w", err)
+	}
+
+	return p, nil
+}
+
+// dummyHash is verified against when the email is unknown, so unknown and
+// known emails take the same time to be rejected.
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := secret.Hash("dummy-password")
+	return h
+})
+
+// Authenticate returns the patron if password matches. Every failure is reported
+// as ErrInvalidCredentials so callers can't find out which emails are registered.
+func (s *PatronService) Authenticate(ctx context.Context, email, password string) (Patron, error) {
+	p, err := s.store.FindByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, ErrNotFound) {
+		secret.Verify(dummyHash(), password)
+		return Patron{}, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return Patron{}, fmt.Errorf("store.FindByEmail: %w", err)
+	}
+
+	ok, err := secret.Verify(p.PasswordHash, password)
+	if err != nil {
+		return Patron{}, fmt.Errorf("secret.Verify: %w", err)
+	}
+
+	if !ok {
+		return Patron{}, ErrInvalidCredentials
+	}
+
+	return p, nil
+}
+
+// GetById returns a patron by its id (the sub of its tokens).
+func (s *PatronService) GetById(ctx context.Context, id string) (Patron, error) {
+	p, err := s.store.FindById(ctx, id)
+	if err != nil {
+		return Patron{}, fmt.Errorf("store.FindById: %w", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,14 @@
+package patron
+
+import (
+	"context"
+	"io"
+)
+
+// Store persists the patrons. Implementations live in the stores package.
+type Store interface {
+	FindById(ctx context.Context, id string) (Patron, error)
+	FindByEmail(ctx context.Context, email string) (Patron, error)
+	Create(ctx context.Context, p Patron) error
+	io.Closer
+}
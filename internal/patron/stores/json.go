@@ -0,0 +1,108 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/go-gin-library-api/internal/patron"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// JSON keeps the patrons in a JSON file, rewritten atomically on every change.
+type JSON struct {
+	mu      sync.Mutex
+	path    string
+	patrons map[string]patron.Patron
+}
+
+// NewJSON loads the patrons file at path, starting empty if it doesn't exist.
+func NewJSON(path string) (*JSON, error) {
+	j := &JSON{
+		path:    path,
+		patrons: map[string]patron.Patron{},
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll: %w", err)
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &j.patrons); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	return j, nil
+}
+
+// persist writes the patrons to a temporary file and renames it over the real one.
+// The file holds password hashes, so it is only readable by the owner.
+func (j *JSON) persist() error {
+	tmp := j.path + ".tmp"
+
+	bytes, err := json.MarshalIndent(j.patrons, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(tmp, bytes, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
+
+func (j *JSON) FindById(ctx context.Context, id string) (patron.Patron, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	p, ok := j.patrons[id]
+	if !ok {
+		return patron.Patron{}, patron.ErrNotFound
+	}
+
+	return p, nil
+}
+
+func (j *JSON) FindByEmail(ctx context.Context, email string) (patron.Patron, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return findByEmail(j.patrons, email)
+}
+
+// Create adds a patron and persists the file.
+func (j *JSON) Create(ctx context.Context, p patron.Patron) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := findByEmail(j.patrons, p.Email); err == nil {
+		return patron.ErrDuplicate
+	}
+
+	j.patrons[p.ID] = p
+	if err := j.persist(); err != nil {
+		delete(j.patrons, p.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (j *JSON) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package stores
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/patron"
+	"sync"
+)
+
+// Memory keeps the patrons on a map; they are lost on restart.
+type Memory struct {
+	mu      sync.RWMutex
+	patrons map[string]patron.Patron // key is the patron id
+}
+
+func NewMemory() *Memory {
+	return &Memory{patrons: map[string]patron.Patron{}}
+}
+
+func (m *Memory) FindById(ctx context.Context, id string) (patron.Patron, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.patrons[id]
+	if !ok {
+		return patron.Patron{}, patron.ErrNotFound
+	}
+
+	return p, nil
+}
+
+func (m *Memory) FindByEmail(ctx context.Context, email string) (patron.Patron, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findByEmail(m.patrons, email)
+}
+
+// Create adds a patron, failing if the email is already registered.
+func (m *Memory) Create(ctx context.Context, p patron.Patron) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := findByEmail(m.patrons, p.Email); err == nil {
+		return patron.ErrDuplicate
+	}
+
+	m.patrons[p.ID] = p
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// findByEmail scans the patrons; a library has few enough members for this to be fine.
+func findByEmail(patrons map[string]patron.Patron, email string) (patron.Patron, error) {
+	for _, p := range patrons {
+		if p.Email == email {
+			return p, nil
+		}
+	}
+
+	return patron.Patron{}, patron.ErrNotFound
+}
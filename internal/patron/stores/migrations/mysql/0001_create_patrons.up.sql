@@ -0,0 +1,10 @@
+-- baseline, same as the table go-gin-library-infra/db/init.sql used to create: a no-op on those databases
+CREATE TABLE IF NOT EXISTS Patrons (
+    id varchar(64) NOT NULL,
+    email varchar(255) NOT NULL,
+    name varchar(255) NOT NULL DEFAULT '',
+    password_hash varchar(255) NOT NULL,
+    created_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY ux_patrons_email (email)
+);
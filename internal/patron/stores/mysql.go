@@ -0,0 +1,106 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"example/go-gin-library-api/internal/migrate"
+	"example/go-gin-library-api/internal/patron"
+	"fmt"
+	"io/fs"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDupEntry is the MySQL error number for a duplicate key.
+const errDupEntry = 1062
+
+// MySQL keeps the patrons in the Patrons table, created by Migrator.
+type MySQL struct {
+	DB *sql.DB
+}
+
+func NewMySQL(dsn string) (*MySQL, error) {
+	dsnCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql.ParseDSN: %w", err)
+	}
+	dsnCfg.ParseTime = true // created_at is scanned into a time.Time
+
+	db, err := sql.Open("mysql", dsnCfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.Ping: %w", err)
+	}
+
+	return &MySQL{DB: db}, nil
+}
+
+// migrations holds the schema of the Patrons table. Add a new numbered file to
+// change it; never edit an applied one.
+//
+//go:embed migrations/mysql
+var migrations embed.FS
+
+// Migrator applies the schema migrations of migrations/mysql, recorded in schema_migrations_patrons
+// so they don't clash with those of the other tables.
+func (s *MySQL) Migrator() (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations/mysql")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithHistory(s.DB, migrate.MySQL, sub, "schema_migrations_patrons")
+}
+
+func (s *MySQL) FindById(ctx context.Context, id string) (patron.Patron, error) {
+	const q = `SELECT id, email, name, password_hash, created_at FROM Patrons WHERE id=?;`
+
+	return scanPatron(s.DB.QueryRowContext(ctx, q, id))
+}
+
+func (s *MySQL) FindByEmail(ctx context.Context, email string) (patron.Patron, error) {
+	const q = `SELECT id, email, name, password_hash, created_at FROM Patrons WHERE email=?;`
+
+	return scanPatron(s.DB.QueryRowContext(ctx, q, email))
+}
+
+// Create inserts a patron; the unique index on email reports duplicates.
+func (s *MySQL) Create(ctx context.Context, p patron.Patron) error {
+	const q = `INSERT INTO Patrons (id, email, name, password_hash, created_at)
+				VALUES (?, ?, ?, ?, ?);`
+
+	_, err := s.DB.ExecContext(ctx, q, p.ID, p.Email, p.Name, p.PasswordHash, p.CreatedAt)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDupEntry {
+		return patron.ErrDuplicate
+	}
+
+	return err
+}
+
+func scanPatron(row *sql.Row) (patron.Patron, error) {
+	var p patron.Patron
+	err := row.Scan(&p.ID, &p.Email, &p.Name, &p.PasswordHash, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return patron.Patron{}, patron.ErrNotFound
+	}
+
+	return p, err
+}
+
+// Close closes the underlying connection pool.
+func (s *MySQL) Close() error {
+	return s.DB.Close()
+}
+
+// Check pings the database, used by the readiness probe.
+func (s *MySQL) Check(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
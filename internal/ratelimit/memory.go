@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full buckets are dropped, so idle keys don't pile up.
+const sweepInterval = time.Minute
+
+type entry struct {
+	bucket
+	fullAt time.Time
+}
+
+// Memory keeps the buckets of this instance in memory.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*entry{}, now: time.Now}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	e, ok := m.buckets[key]
+	if !ok {
+		e = &entry{}
+		m.buckets[key] = e
+	}
+
+	res := e.take(limit, now)
+	e.fullAt = e.full(limit)
+	return res, nil
+}
+
+// sweep drops the buckets that refilled completely; a missing bucket starts full anyway.
+func (m *Memory) sweep(now time.Time) {
+	for key, e := range m.buckets {
+		if !now.Before(e.fullAt) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a settable time for Memory.now.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestMemory() (*Memory, *clock) {
+	c := &clock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	m := NewMemory()
+	m.now = c.now
+	return m, c
+}
+
+func take(t *testing.T, m *Memory, key string, limit Limit) Result {
+	t.Helper()
+
+	res, err := m.Take(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestMemoryBurstAndRefill(t *testing.T) {
+	m, c := newTestMemory()
+	limit := Limit{Requests: 2, Per: time.Second, Burst: 4}
+
+	// a new bucket is full: the whole burst goes through at once
+	for want := 3; want >= 0; want-- {
+		res := take(t, m, "k", limit)
+		if !res.Allowed || res.Remaining != want || res.Limit != 4 {
+			t.Fatalf("take within the burst = %+v, want allowed with %d remaining", res, want)
+		}
+	}
+
+	res := take(t, m, "k", limit)
+	if res.Allowed {
+		t.Fatal("take past the burst was allowed")
+	}
+	if res.RetryAfter != 500*time.Millisecond || res.Reset != 2*time.Second {
+		t.Errorf("empty bucket: RetryAfter %s, Reset %s, want 500ms and 2s", res.RetryAfter, res.Reset)
+	}
+
+	// two tokens a second come back
+	c.advance(500 * time.Millisecond)
+	if res := take(t, m, "k", limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("take after refilling one token = %+v", res)
+	}
+	if res := take(t, m, "k", limit); res.Allowed {
+		t.Error("the refilled token was handed out twice")
+	}
+
+	// refilling stops at the burst
+	c.advance(time.Hour)
+	for range 4 {
+		if res := take(t, m, "k", limit); !res.Allowed {
+			t.Fatal("take within the burst after a quiet period was refused")
+		}
+	}
+	if res := take(t, m, "k", limit); res.Allowed {
+		t.Error("the bucket refilled past its burst")
+	}
+}
+
+func TestMemoryBurstDefaultsToRequests(t *testing.T) {
+	m, _ := newTestMemory()
+	limit := Limit{Requests: 3, Per: time.Minute}
+
+	for range 3 {
+		if res := take(t, m, "k", limit); !res.Allowed || res.Limit != 3 {
+			t.Fatalf("take = %+v, want allowed with a limit of 3", res)
+		}
+	}
+
+	res := take(t, m, "k", limit)
+	if res.Allowed || res.RetryAfter != 20*time.Second {
+		t.Errorf("take past the limit = %+v, want refused for 20s", res)
+	}
+}
+
+func TestMemoryKeysAreSeparate(t *testing.T) {
+	m, _ := newTestMemory()
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	take(t, m, "a", limit)
+	if res := take(t, m, "b", limit); !res.Allowed {
+		t.Error("b was limited by the requests of a")
+	}
+}
+
+func TestMemorySweepsFullBuckets(t *testing.T) {
+	m, c := newTestMemory()
+
+	take(t, m, "fast", Limit{Requests: 10, Per: time.Second})
+	take(t, m, "slow", Limit{Requests: 1, Per: time.Hour})
+
+	// fast is full again after 100ms, slow after an hour
+	c.advance(sweepInterval)
+	take(t, m, "other", Limit{Requests: 1, Per: time.Second})
+
+	if _, ok := m.buckets["fast"]; ok {
+		t.Error("the full bucket wasn't swept")
+	}
+	if _, ok := m.buckets["slow"]; !ok {
+		t.Error("a bucket still refilling was swept")
+	}
+
+	// a swept bucket starts full again
+	if res := take(t, m, "fast", Limit{Requests: 10, Per: time.Second}); res.Remaining != 9 {
+		t.Errorf("take on a swept key = %+v, want 9 remaining", res)
+	}
+}
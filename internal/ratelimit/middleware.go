@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy tells which limit applies to a request. Routes names the limit of each
+// route group; a client listed in ClientTiers gets the limits of its tier instead
+// where the tier sets one. Route groups without a limit aren't limited.
+type Policy struct {
+	Routes      map[string]Limit
+	Tiers       map[string]map[string]Limit // tier -> route -> limit
+	ClientTiers map[string]string           // client id -> tier
+}
+
+func (p Policy) limit(route, clientID string) Limit {
+	if tier, ok := p.ClientTiers[clientID]; ok && clientID != "" {
+		if l, ok := p.Tiers[tier][route]; ok && l.Enabled() {
+			return l
+		}
+	}
+
+	return p.Routes[route]
+}
+
+// Limiter counts requests against the policy.
+type Limiter struct {
+	backend Backend
+	policy  Policy
+}
+
+func NewLimiter(backend Backend, policy Policy) *Limiter {
+	return &Limiter{backend: backend, policy: policy}
+}
+
+// ByIP limits the requests of a route group per client IP, for the routes
+// used before a client is authenticated.
+func (l *Limiter) ByIP(route string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		l.handle(ctx, route, "", "ip:"+ctx.ClientIP())
+	}
+}
+
+// ByClient limits the requests of a route group per client; it must run after
+// auth.RequireAuth, which sets client_id. Requests without one fall back to the IP.
+func (l *Limiter) ByClient(route string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		clientID := ctx.GetString("client_id")
+		if clientID == "" {
+			l.handle(ctx, route, "", "ip:"+ctx.ClientIP())
+			return
+		}
+
+		l.handle(ctx, route, clientID, "client:"+clientID)
+	}
+}
+
+func (l *Limiter) handle(ctx *gin.Context, route, clientID, key string) {
+	limit := l.policy.limit(route, clientID)
+	if !limit.Enabled() {
+		ctx.Next()
+		return
+	}
+
+	res, err := l.backend.Take(ctx, route+":"+key, limit)
+	if err != nil {
+		// an unreachable shared backend must not take the API down with it
+		log.Printf("ratelimit.Take: %s", err.Error())
+		ctx.Next()
+		return
+	}
+
+	// headers of draft-ietf-httpapi-ratelimit-headers
+	ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	ctx.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, ceilSeconds(limit.Per), res.Limit))
+
+	if !res.Allowed {
+		ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+
+	ctx.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newRouter serves GET /token limited by IP and GET /api limited by the client
+// named in the X-Client header, standing in for auth.RequireAuth.
+func newRouter(backend Backend, policy Policy) *gin.Engine {
+	l := NewLimiter(backend, policy)
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+	r := gin.New()
+	r.GET("/token", l.ByIP("token"), ok)
+	r.GET("/api", func(ctx *gin.Context) {
+		if id := ctx.GetHeader("X-Client"); id != "" {
+			ctx.Set("client_id", id)
+		}
+	}, l.ByClient("api"), ok)
+
+	return r
+}
+
+func get(r http.Handler, path, ip, client string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if client != "" {
+		req.Header.Set("X-Client", client)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareHeadersAnd429(t *testing.T) {
+	m, c := newTestMemory()
+	r := newRouter(m, Policy{Routes: map[string]Limit{"token": {Requests: 2, Per: time.Minute}}})
+
+	w := get(r, "/token", "10.0.0.1", "")
+	want := map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "30",
+		"RateLimit-Policy":    "2;w=60;burst=2",
+		"Retry-After":         "",
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: %d", w.Code)
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	get(r, "/token", "10.0.0.1", "")
+	w = get(r, "/token", "10.0.0.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the limit: %d, want 429", w.Code)
+	}
+	for name, value := range map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": "30"} {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("429 %s = %q, want %q", name, got, value)
+		}
+	}
+	if body := w.Body.String(); body != `{"error":"rate limit exceeded"}` {
+		t.Errorf("429 body = %s", body)
+	}
+
+	// another IP has its own bucket
+	if w := get(r, "/token", "10.0.0.2", ""); w.Code != http.StatusOK {
+		t.Errorf("request from another IP: %d", w.Code)
+	}
+
+	// and the first one gets a request through once a token came back
+	c.advance(30 * time.Second)
+	if w := get(r, "/token", "10.0.0.1", ""); w.Code != http.StatusOK {
+		t.Errorf("request after Retry-After: %d", w.Code)
+	}
+}
+
+func TestMiddlewareTierOverride(t *testing.T) {
+	m, _ := newTestMemory()
+	r := newRouter(m, Policy{
+		Routes: map[string]Limit{"api": {Requests: 1, Per: time.Minute}},
+		Tiers: map[string]map[string]Limit{
+			"gold":  {"api": {Requests: 3, Per: time.Minute}},
+			"empty": {},
+		},
+		ClientTiers: map[string]string{"vip": "gold", "plain": "empty"},
+	})
+
+	tests := []struct {
+		client  string
+		allowed int
+	}{
+		{"vip", 3},
+		{"regular", 1},
+		{"plain", 1}, // a tier without a limit for the route keeps the default
+	}
+
+	for _, tt := range tests {
+		for i := range tt.allowed + 1 {
+			w := get(r, "/api", "10.0.0.1", tt.client)
+			if want := i < tt.allowed; (w.Code == http.StatusOK) != want {
+				t.Errorf("%s request %d: %d", tt.client, i+1, w.Code)
+			}
+		}
+	}
+
+	// a request without a client falls back to the IP, apart from the clients' buckets
+	if w := get(r, "/api", "10.0.0.1", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("request without a client: %d, limit %q", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestMiddlewareUnlimitedRoute(t *testing.T) {
+	m, _ := newTestMemory()
+	r := newRouter(m, Policy{})
+
+	for range 5 {
+		w := get(r, "/token", "10.0.0.1", "")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("unlimited route: %d with RateLimit-Limit %q", w.Code, w.Header().Get("RateLimit-Limit"))
+		}
+	}
+}
+
+type failingBackend struct{}
+
+func (failingBackend) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("backend unreachable")
+}
+
+func TestMiddlewareBackendFailureLetsRequestsThrough(t *testing.T) {
+	r := newRouter(failingBackend{}, Policy{Routes: map[string]Limit{"token": {Requests: 1, Per: time.Minute}}})
+
+	for range 3 {
+		if w := get(r, "/token", "10.0.0.1", ""); w.Code != http.StatusOK {
+			t.Fatalf("request with the backend down: %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Requests are allowed every Per, with up to Burst of
+// them at once after a quiet period. Burst defaults to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Enabled reports whether the limit allows anything at all to be counted;
+// a zero Limit means the route isn't limited.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return float64(l.Requests)
+}
+
+// rate is how many tokens are added back per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result is the state of a bucket after a request was counted.
+type Result struct {
+	Allowed    bool
+	Limit      int           // capacity of the bucket
+	Remaining  int           // requests left right now
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, zero when Allowed
+}
+
+// Backend keeps the buckets. Memory serves a single instance; deployments running
+// several instances plug in a shared one (Redis, a database...) so a client's
+// limit holds across all of them. Take must be atomic for a given key.
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket is the token bucket state of one key.
+type bucket struct {
+	Tokens float64
+	Last   time.Time
+}
+
+// take refills b up to now and takes one token if there is one.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity, rate := limit.capacity(), limit.rate()
+
+	if b.Last.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Last).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+	b.Last = now
+
+	res := Result{Limit: int(capacity)}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((capacity - b.Tokens) / rate)
+	return res
+}
+
+// full is when the bucket has refilled completely and can be forgotten.
+func (b *bucket) full(limit Limit) time.Time {
+	return b.Last.Add(seconds((limit.capacity() - b.Tokens) / limit.rate()))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,167 @@
+// Package tlsutil builds the server TLS configuration, reloading the
+// certificate when its files change so renewals don't need a restart.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config is what the server needs to serve TLS.
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   string // "1.2" or "1.3"
+	ClientCAFile string // enables optional mTLS: client certificates are requested and verified against it
+}
+
+// ParseVersion maps "1.2" and "1.3" to their crypto/tls constants.
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", v)
+	}
+}
+
+// Reloader serves the certificate loaded from CertFile and KeyFile and loads
+// it again when either file changes.
+type Reloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloader loads the certificate, failing if it can't be read.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, stop: make(chan struct{}), done: make(chan struct{})}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the files again if they changed since the last load, and reports
+// whether the certificate was replaced. A broken pair keeps the previous one in use.
+func (r *Reloader) Reload() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls.LoadX509KeyPair: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch checks the files every interval until Stop is called.
+func (r *Reloader) Watch(interval time.Duration, logf func(format string, args ...any)) {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				reloaded, err := r.Reload()
+				if err != nil {
+					logf("Reloader.Reload: %s", err.Error())
+				} else if reloaded {
+					logf("Reloaded TLS certificate from %s", r.certFile)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the watch loop and waits for it to exit.
+func (r *Reloader) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// NewServerConfig builds the tls.Config of the server around r. With a client
+// CA, certificates are requested but optional: clients without one still
+// authenticate with their secret.
+func NewServerConfig(cfg Config, r *Reloader) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: r.GetCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificate found", cfg.ClientCAFile)
+		}
+
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tc, nil
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Service decorates a book.Service with one span per method call.
+type Service struct {
+	next   book.Service
+	tracer trace.Tracer
+}
+
+func NewService(next book.Service) *Service {
+	return &Service{next: next, tracer: otel.Tracer("example/go-gin-library-api/internal/book")}
+}
+
+func (s *Service) FindAll(ctx context.Context, filters book.BookFilters) ([]book.Book, error) {
+	ctx, span := s.tracer.Start(ctx, "BookService.FindAll", trace.WithAttributes(
+		attribute.String("book.filter.title", filters.Title),
+		attribute.String("book.filter.author", filters.Author),
+		attribute.Bool("book.filter.prefix", filters.Prefix),
+	))
+	defer span.End()
+
+	out, err := s.next.FindAll(ctx, filters)
+	end(span, err)
+	return out, err
+}
+
+func (s *Service) GetById(ctx context.Context, id string) (book.Book, error) {
+	ctx, span := s.tracer.Start(ctx, "BookService.GetById", trace.WithAttributes(attribute.String("book.id", id)))
+	defer span.End()
+
+	out, err := s.next.GetById(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *Service) Create(ctx context.Context, bookRequest book.BookRequest) (string, error) {
+	ctx, span := s.tracer.Start(ctx, "BookService.Create")
+	defer span.End()
+
+	out, err := s.next.Create(ctx, bookRequest)
+	span.SetAttributes(attribute.String("book.id", out))
+	end(span, err)
+	return out, err
+}
+
+func (s *Service) Checkout(ctx context.Context, id string) (book.Book, error) {
+	ctx, span := s.tracer.Start(ctx, "BookService.Checkout", trace.WithAttributes(attribute.String("book.id", id)))
+	defer span.End()
+
+	out, err := s.next.Checkout(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *Service) Return(ctx context.Context, id string) (book.Book, error) {
+	ctx, span := s.tracer.Start(ctx, "BookService.Return", trace.WithAttributes(attribute.String("book.id", id)))
+	defer span.End()
+
+	out, err := s.next.Return(ctx, id)
+	end(span, err)
+	return out, err
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"example/go-gin-library-api/internal/book"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store decorates a book.Store with one span per method call.
+type Store struct {
+	next    book.Store
+	backend string
+	tracer  trace.Tracer
+}
+
+// NewStore wraps next; backend is recorded as the book.store.backend attribute.
+func NewStore(next book.Store, backend string) *Store {
+	return &Store{next: next, backend: backend, tracer: otel.Tracer("example/go-gin-library-api/internal/book/stores")}
+}
+
+// start opens a client span named after the store method.
+func (s *Store) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("book.store.backend", s.backend))
+	return s.tracer.Start(ctx, "Store."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (s *Store) List(ctx context.Context) ([]book.Book, error) {
+	ctx, span := s.start(ctx, "List")
+	defer span.End()
+
+	out, err := s.next.List(ctx)
+	end(span, err)
+	return out, err
+}
+
+func (s *Store) FindById(ctx context.Context, id string) (book.Book, error) {
+	ctx, span := s.start(ctx, "FindById", attribute.String("book.id", id))
+	defer span.End()
+
+	out, err := s.next.FindById(ctx, id)
+	end(span, err)
+	return out, err
+}
+
+func (s *Store) Create(ctx context.Context, b book.Book) (string, error) {
+	ctx, span := s.start(ctx, "Create", attribute.String("book.id", b.ID))
+	defer span.End()
+
+	out, err := s.next.Create(ctx, b)
+	end(span, err)
+	return out, err
+}
+
+func (s *Store) Update(ctx context.Context, b book.Book) error {
+	ctx, span := s.start(ctx, "Update", attribute.String("book.id", b.ID))
+	defer span.End()
+
+	err := s.next.Update(ctx, b)
+	end(span, err)
+	return err
+}
+
+// Modify goes through book.Modify, so it stays atomic when the wrapped store is a book.Modifier.
+func (s *Store) Modify(ctx context.Context, id string, fn func(*book.Book) error) (book.Book, error) {
+	ctx, span := s.start(ctx, "Modify", attribute.String("book.id", id))
+	defer span.End()
+
+	out, err := book.Modify(ctx, s.next, id, fn)
+	end(span, err)
+	return out, err
+}
+
+// WithTx spans the whole transaction; calls made within it get their own spans.
+func (s *Store) WithTx(ctx context.Context, fn func(tx book.Store) error) error {
+	ctx, span := s.start(ctx, "WithTx")
+	defer span.End()
+
+	err := s.next.WithTx(ctx, func(tx book.Store) error {
+		return fn(&Store{next: tx, backend: s.backend, tracer: s.tracer})
+	})
+	end(span, err)
+	return err
+}
+
+func (s *Store) FindByTitle(ctx context.Context, title string) ([]book.Book, error) {
+	ctx, span := s.start(ctx, "FindByTitle", attribute.String("book.title", title))
+	defer span.End()
+
+	out, err := s.next.FindByTitle(ctx, title)
+	end(span, err)
+	return out, err
+}
+
+func (s *Store) FindByAuthor(ctx context.Context, author string) ([]book.Book, error) {
+	ctx, span := s.start(ctx, "FindByAuthor", attribute.String("book.author", author))
+	defer span.End()
+
+	out, err := s.next.FindByAuthor(ctx, author)
+	end(span, err)
+	return out, err
+}
+
+func (s *Store) Close() error {
+	return s.next.Close()
+}
+
+// end marks the span as failed when err is not nil.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}